@@ -0,0 +1,119 @@
+package batch
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Mode controls how a Result reacts to a failed record
+type Mode int
+
+// Acceptable `Mode` values
+const (
+	// ContinueOnFailure keeps processing after a failure and reports only the records that failed
+	ContinueOnFailure Mode = iota
+	// StopOnFirstFailure stops processing at the first failure and reports that record and every record after it.
+	// Kinesis checkpoints at the lowest reported sequence number, so any record after a failure is replayed anyway.
+	StopOnFirstFailure
+)
+
+var mode_name = map[Mode]string{
+	ContinueOnFailure:  "continue",
+	StopOnFirstFailure: "stop",
+}
+
+var mode_value = map[string]Mode{
+	"continue": ContinueOnFailure,
+	"stop":     StopOnFirstFailure,
+}
+
+// ModeFromString converts a string into a Mode.
+// If m is not a valid Mode then ContinueOnFailure will be returned
+func ModeFromString(m string) Mode {
+	return mode_value[strings.TrimSpace(strings.ToLower(m))]
+}
+
+func (m Mode) String() string {
+	return mode_name[m]
+}
+
+// Result collects the failed records of a single batch and renders them as a partial batch response.
+// Item identifiers are reported in the order they are added, which should match the order of the batch.
+type Result struct {
+	mode        Mode
+	failures    []string
+	failed      map[string]bool
+	halted      bool
+	unprocessed int
+}
+
+// NewResult returns an empty Result that applies the given Mode
+func NewResult(mode Mode) *Result {
+	return &Result{mode: mode, failed: map[string]bool{}}
+}
+
+// Fail reports the record identified by id as failed.
+// Records sharing an id, such as the user records of a KPL aggregated record, are reported once.
+// When the Result uses StopOnFirstFailure the first call halts the batch.
+func (r *Result) Fail(id string) {
+	if !r.failed[id] {
+		r.failed[id] = true
+		r.failures = append(r.failures, id)
+	}
+	if r.mode == StopOnFirstFailure {
+		r.halted = true
+	}
+}
+
+// Halted reports whether no further records should be processed.
+// Once halted, callers should Fail every remaining record without processing it.
+func (r *Result) Halted() bool {
+	return r.halted
+}
+
+// Unprocessed returns the number of records that were never started, for example because the invocation deadline was near
+func (r *Result) Unprocessed() int {
+	return r.unprocessed
+}
+
+// Failures returns the identifiers of every failed record
+func (r *Result) Failures() []string {
+	return r.failures
+}
+
+// KinesisEventResponse renders the failures as a Kinesis partial batch response.
+// An empty response tells Lambda that the whole batch succeeded.
+func (r *Result) KinesisEventResponse() events.KinesisEventResponse {
+	response := events.KinesisEventResponse{
+		BatchItemFailures: make([]events.KinesisBatchItemFailure, 0, len(r.failures)),
+	}
+	for _, id := range r.failures {
+		response.BatchItemFailures = append(response.BatchItemFailures, events.KinesisBatchItemFailure{ItemIdentifier: id})
+	}
+	return response
+}
+
+// DynamoDBEventResponse renders the failures as a DynamoDB Streams partial batch response.
+// Like Kinesis, DynamoDB Streams resumes from the lowest reported sequence number.
+func (r *Result) DynamoDBEventResponse() events.DynamoDBEventResponse {
+	response := events.DynamoDBEventResponse{
+		BatchItemFailures: make([]events.DynamoDBBatchItemFailure, 0, len(r.failures)),
+	}
+	for _, id := range r.failures {
+		response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{ItemIdentifier: id})
+	}
+	return response
+}
+
+// SQSEventResponse renders the failures as an SQS partial batch response.
+// Lambda deletes every message of the batch that is not reported.
+func (r *Result) SQSEventResponse() events.SQSEventResponse {
+	response := events.SQSEventResponse{
+		BatchItemFailures: make([]events.SQSBatchItemFailure, 0, len(r.failures)),
+	}
+	for _, id := range r.failures {
+		response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: id})
+	}
+	return response
+}
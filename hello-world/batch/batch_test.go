@@ -0,0 +1,103 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestModeFromString(t *testing.T) {
+	tests := map[string]Mode{
+		"":         ContinueOnFailure,
+		"continue": ContinueOnFailure,
+		"stop":     StopOnFirstFailure,
+		" Stop ":   StopOnFirstFailure,
+		"unknown":  ContinueOnFailure,
+	}
+	for input, expected := range tests {
+		if got := ModeFromString(input); got != expected {
+			t.Errorf("ModeFromString(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
+
+func TestResult(t *testing.T) {
+	t.Run("Continue on failure", func(t *testing.T) {
+		result := NewResult(ContinueOnFailure)
+		result.Fail("1")
+		if result.Halted() {
+			t.Fatal("ContinueOnFailure should never halt")
+		}
+		result.Fail("3")
+		result.Fail("3")
+
+		response := result.KinesisEventResponse()
+		if len(response.BatchItemFailures) != 2 || response.BatchItemFailures[1].ItemIdentifier != "3" {
+			t.Fatalf("Unexpected response %+v", response)
+		}
+		dynamoDBResponse := result.DynamoDBEventResponse()
+		if len(dynamoDBResponse.BatchItemFailures) != 2 || dynamoDBResponse.BatchItemFailures[1].ItemIdentifier != "3" {
+			t.Fatalf("Unexpected response %+v", dynamoDBResponse)
+		}
+		sqsResponse := result.SQSEventResponse()
+		if len(sqsResponse.BatchItemFailures) != 2 || sqsResponse.BatchItemFailures[1].ItemIdentifier != "3" {
+			t.Fatalf("Unexpected response %+v", sqsResponse)
+		}
+	})
+
+	t.Run("Stop on first failure", func(t *testing.T) {
+		result := NewResult(StopOnFirstFailure)
+		if result.Halted() {
+			t.Fatal("Result should not halt before a failure")
+		}
+		result.Fail("2")
+		if !result.Halted() {
+			t.Fatal("StopOnFirstFailure should halt after a failure")
+		}
+	})
+}
+
+func TestKindOf(t *testing.T) {
+	base := errors.New("boom")
+
+	if KindOf(base) != KindRetryable {
+		t.Error("Unclassified errors should be retryable")
+	}
+	if !IsPermanent(fmt.Errorf("wrapped: %w", Permanent(base))) {
+		t.Error("Permanent should survive wrapping")
+	}
+	if KindOf(Retryable(fmt.Errorf("override: %w", Permanent(base)))) != KindRetryable {
+		t.Error("The outermost classification should win")
+	}
+	if !errors.Is(Permanent(base), base) {
+		t.Error("Permanent should unwrap to the original error")
+	}
+	if Permanent(nil) != nil || Retryable(nil) != nil {
+		t.Error("Wrapping a nil error should return nil")
+	}
+}
+
+func TestWithSafetyMargin(t *testing.T) {
+	t.Run("Deadline is moved earlier", func(t *testing.T) {
+		parent, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		parentDeadline, _ := parent.Deadline()
+
+		ctx, cancel := WithSafetyMargin(parent, time.Second)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		if !ok || !deadline.Equal(parentDeadline.Add(-time.Second)) {
+			t.Fatalf("Expected deadline %v, got %v", parentDeadline.Add(-time.Second), deadline)
+		}
+	})
+
+	t.Run("No deadline", func(t *testing.T) {
+		ctx, cancel := WithSafetyMargin(context.Background(), time.Second)
+		defer cancel()
+		if _, ok := ctx.Deadline(); ok {
+			t.Fatal("Expected no deadline")
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultSafetyMargin is the time left before the Lambda deadline at which no new records are started
+const DefaultSafetyMargin = 500 * time.Millisecond
+
+// WithSafetyMargin returns a copy of ctx whose deadline is margin earlier than the deadline of ctx.
+// The Lambda runtime sets the invocation deadline on the handler context, so once the returned context is done
+// there is only margin left to return a partial batch response before the invocation times out.
+// If ctx has no deadline it is returned unchanged apart from the added CancelFunc.
+func WithSafetyMargin(ctx context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+// SafetyMarginFromString parses a duration such as "750ms".
+// An empty string returns DefaultSafetyMargin.
+func SafetyMarginFromString(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultSafetyMargin, nil
+	}
+	margin, err := time.ParseDuration(s)
+	if err != nil || margin < 0 {
+		return 0, fmt.Errorf("invalid safety margin %q", s)
+	}
+	return margin, nil
+}
@@ -0,0 +1,82 @@
+package batch
+
+import (
+	"errors"
+)
+
+// Kind classifies a record failure by whether retrying the record can ever succeed
+type Kind int
+
+// Acceptable `Kind` values
+const (
+	// KindRetryable failures are reported as batch item failures so the record is delivered again.
+	// This is the Kind of any error that has not been classified.
+	KindRetryable Kind = iota
+	// KindPermanent failures can never succeed no matter how often the record is retried.
+	// These records are routed to the poison record path instead of being reported as batch item failures.
+	KindPermanent
+)
+
+var kind_name = map[Kind]string{
+	KindRetryable: "Retryable",
+	KindPermanent: "Permanent",
+}
+
+func (k Kind) String() string {
+	return kind_name[k]
+}
+
+// kindError attaches a Kind to an error while leaving it available to errors.Is and errors.As
+type kindError struct {
+	kind Kind
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as a failure that will never succeed on retry.
+// Permanent returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: KindPermanent, err: err}
+}
+
+// Retryable marks err as a failure that may succeed on retry.
+// Wrapping is only needed to override a Permanent error further down the chain.
+// Retryable returns nil if err is nil.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: KindRetryable, err: err}
+}
+
+// KindOf returns the Kind of the outermost Permanent or Retryable wrapper in err's chain.
+// Errors that were never classified are KindRetryable.
+func KindOf(err error) Kind {
+	var k *kindError
+	if errors.As(err, &k) {
+		return k.kind
+	}
+	return KindRetryable
+}
+
+// IsPermanent reports whether err was marked Permanent
+func IsPermanent(err error) bool {
+	return KindOf(err) == KindPermanent
+}
+
+// Classifier decides the Kind of a record failure.
+// Custom classifiers can recognise errors from downstream libraries that were not wrapped by the caller.
+type Classifier func(err error) Kind
+
+// DefaultClassifier classifies errors by their Permanent and Retryable wrappers only
+var DefaultClassifier Classifier = KindOf
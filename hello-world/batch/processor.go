@@ -0,0 +1,136 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Record is what the Processor needs to know about a record in the batch
+type Record struct {
+	// ID is the item identifier reported when the record fails, such as a Kinesis sequence number
+	ID string
+	// Key groups records that must be processed in order, such as a Kinesis partition key
+	Key string
+}
+
+// Func processes the record at index i of the batch.
+// It returns an error when the record must be reported as a batch item failure.
+// Records that were taken care of some other way, such as dead-lettering, return nil.
+type Func func(ctx context.Context, i int) error
+
+// Processor runs a Func over every record of a batch and collects a Result
+type Processor struct {
+	// Mode controls how the batch reacts to a failed record
+	Mode Mode
+	// SafetyMargin is the time kept in reserve before the invocation deadline, see WithSafetyMargin
+	SafetyMargin time.Duration
+	// Concurrency is the number of record keys processed at the same time.
+	// Records sharing a Key are always processed one at a time in batch order.
+	// Values below 2 process the whole batch sequentially in batch order.
+	Concurrency int
+	// SkipKeyAfterFailure leaves the later records of a Key unprocessed once one of its records fails, even when sequential.
+	// Sources that delete every record not reported as failed, such as SQS FIFO queues, need it to keep a Key in order.
+	SkipKeyAfterFailure bool
+}
+
+type recordStatus int
+
+const (
+	statusUnprocessed recordStatus = iota
+	statusSucceeded
+	statusFailed
+)
+
+// Process runs fn over records and returns the failed records as a Result.
+// Records that were never started, because the batch halted or the invocation deadline was near, are reported as failed.
+func (p Processor) Process(ctx context.Context, records []Record, fn Func) *Result {
+	// Timing out would retry the whole batch, so stop early enough to report what was processed
+	ctx, cancel := WithSafetyMargin(ctx, p.SafetyMargin)
+	defer cancel()
+
+	statuses := make([]recordStatus, len(records))
+	var halted atomic.Bool
+
+	// run processes one group of record indices in order
+	run := func(indices []int, skipAfterFailure bool) {
+		failedKeys := map[string]bool{}
+		for _, i := range indices {
+			if halted.Load() {
+				return
+			}
+			if ctx.Err() != nil {
+				halted.Store(true)
+				return
+			}
+
+			if failedKeys[records[i].Key] {
+				continue
+			}
+
+			if err := fn(ctx, i); err != nil {
+				statuses[i] = statusFailed
+				if p.Mode == StopOnFirstFailure {
+					halted.Store(true)
+				}
+				// Later records for the same key must not be applied before this one succeeds
+				if skipAfterFailure {
+					failedKeys[records[i].Key] = true
+				}
+				continue
+			}
+			statuses[i] = statusSucceeded
+		}
+	}
+
+	if p.Concurrency < 2 {
+		indices := make([]int, len(records))
+		for i := range records {
+			indices[i] = i
+		}
+		run(indices, p.SkipKeyAfterFailure)
+	} else {
+		groups := groupByKey(records)
+		sem := make(chan struct{}, p.Concurrency)
+		var wg sync.WaitGroup
+		for _, indices := range groups {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(indices []int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				run(indices, true)
+			}(indices)
+		}
+		wg.Wait()
+	}
+
+	result := NewResult(p.Mode)
+	for i, record := range records {
+		if statuses[i] == statusUnprocessed {
+			result.unprocessed++
+		}
+		if result.Halted() || statuses[i] != statusSucceeded {
+			result.Fail(record.ID)
+		}
+	}
+	return result
+}
+
+// groupByKey splits records into groups of indices sharing a Key.
+// Groups are ordered by their first record and indices within a group keep batch order.
+func groupByKey(records []Record) [][]int {
+	var groups [][]int
+	position := map[string]int{}
+	for i, record := range records {
+		g, ok := position[record.Key]
+		if !ok {
+			g = len(groups)
+			position[record.Key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
@@ -0,0 +1,155 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testRecords(keys ...string) []Record {
+	records := make([]Record, len(keys))
+	for i, key := range keys {
+		records[i] = Record{ID: fmt.Sprint(i + 1), Key: key}
+	}
+	return records
+}
+
+func TestProcessor(t *testing.T) {
+	t.Run("Sequential continue on failure", func(t *testing.T) {
+		p := Processor{Mode: ContinueOnFailure}
+		result := p.Process(context.Background(), testRecords("a", "a", "a", "b"), func(_ context.Context, i int) error {
+			if i == 1 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+		if got := fmt.Sprint(result.Failures()); got != "[2]" {
+			t.Fatalf("Expected failures [2], got %s", got)
+		}
+	})
+
+	t.Run("Sequential failure skips the rest of the key", func(t *testing.T) {
+		p := Processor{Mode: ContinueOnFailure, SkipKeyAfterFailure: true}
+		var processed []int
+		result := p.Process(context.Background(), testRecords("a", "a", "b", "a"), func(_ context.Context, i int) error {
+			processed = append(processed, i)
+			if i == 0 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+		if got := fmt.Sprint(result.Failures()); got != "[1 2 4]" {
+			t.Fatalf("Expected failures [1 2 4], got %s", got)
+		}
+		if got := fmt.Sprint(processed); got != "[0 2]" {
+			t.Fatalf("Expected records [0 2] to be processed, got %s", got)
+		}
+		if result.Unprocessed() != 2 {
+			t.Fatalf("Expected 2 unprocessed records, got %d", result.Unprocessed())
+		}
+	})
+
+	t.Run("Concurrent failure skips the rest of the key", func(t *testing.T) {
+		p := Processor{Mode: ContinueOnFailure, Concurrency: 4}
+		var mu sync.Mutex
+		var processed []int
+		result := p.Process(context.Background(), testRecords("a", "b", "a", "b", "a"), func(_ context.Context, i int) error {
+			mu.Lock()
+			processed = append(processed, i)
+			mu.Unlock()
+			if i == 2 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+		if got := fmt.Sprint(result.Failures()); got != "[3 5]" {
+			t.Fatalf("Expected failures [3 5], got %s", got)
+		}
+		for _, i := range processed {
+			if i == 4 {
+				t.Fatal("Record 5 should not be processed after record 3 failed")
+			}
+		}
+	})
+
+	t.Run("Concurrent stop on first failure", func(t *testing.T) {
+		p := Processor{Mode: StopOnFirstFailure, Concurrency: 4}
+		// Record 1 only fails once record 0 is done, otherwise the halt could leave record 0 unprocessed
+		first := make(chan struct{})
+		result := p.Process(context.Background(), testRecords("a", "b", "c", "d"), func(_ context.Context, i int) error {
+			switch i {
+			case 0:
+				close(first)
+			case 1:
+				<-first
+				return errors.New("boom")
+			}
+			return nil
+		})
+		if got := fmt.Sprint(result.Failures()); got != "[2 3 4]" {
+			t.Fatalf("Expected failures [2 3 4], got %s", got)
+		}
+	})
+
+	t.Run("Order is preserved within a key", func(t *testing.T) {
+		p := Processor{Concurrency: 3}
+		keys := []string{"a", "b", "c", "a", "b", "c", "a", "b", "c"}
+		var mu sync.Mutex
+		seen := map[string][]int{}
+		result := p.Process(context.Background(), testRecords(keys...), func(_ context.Context, i int) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			seen[keys[i]] = append(seen[keys[i]], i)
+			mu.Unlock()
+			return nil
+		})
+		if len(result.Failures()) != 0 {
+			t.Fatalf("Expected no failures, got %v", result.Failures())
+		}
+		if got := fmt.Sprint(seen["a"], seen["b"], seen["c"]); got != "[0 3 6] [1 4 7] [2 5 8]" {
+			t.Fatalf("Unexpected processing order %s", got)
+		}
+	})
+
+	t.Run("Concurrency limit", func(t *testing.T) {
+		p := Processor{Concurrency: 2}
+		var running, peak int32
+		var mu sync.Mutex
+		p.Process(context.Background(), testRecords("a", "b", "c", "d", "e", "f"), func(_ context.Context, i int) error {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+		if peak > 2 {
+			t.Fatalf("Expected at most 2 keys at a time, got %d", peak)
+		}
+	})
+
+	t.Run("Deadline reports unprocessed records", func(t *testing.T) {
+		// Cancelling during the first record stands in for reaching the safety margin
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p := Processor{SafetyMargin: time.Minute}
+		result := p.Process(ctx, testRecords("a", "a", "a"), func(_ context.Context, i int) error {
+			cancel()
+			return nil
+		})
+		if got := fmt.Sprint(result.Failures()); got != "[2 3]" {
+			t.Fatalf("Expected failures [2 3], got %s", got)
+		}
+		if result.Unprocessed() != 2 {
+			t.Fatalf("Expected 2 unprocessed records, got %d", result.Unprocessed())
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the circuit is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Default thresholds, see Config
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 30 * time.Second
+	DefaultHalfOpenMaxCalls = 1
+)
+
+// State is the state of a Breaker
+type State int
+
+// Acceptable `State` values
+const (
+	// Closed lets every call through and counts consecutive failures
+	Closed State = iota
+	// Open rejects every call until the open timeout has passed
+	Open
+	// HalfOpen lets a limited number of trial calls through to decide whether to close or open again
+	HalfOpen
+)
+
+var state_name = map[State]string{
+	Closed:   "closed",
+	Open:     "open",
+	HalfOpen: "half-open",
+}
+
+func (s State) String() string {
+	return state_name[s]
+}
+
+// MarshalText renders the State by name, so statuses read well as JSON
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Config holds the thresholds of a Breaker.
+// Zero values are replaced by their defaults.
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before trial calls are let through
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls is the number of trial calls allowed at the same time while half-open
+	HalfOpenMaxCalls int
+}
+
+// DefaultConfig returns a Config with every threshold set to its default
+func DefaultConfig() Config {
+	return Config{
+		FailureThreshold: DefaultFailureThreshold,
+		OpenTimeout:      DefaultOpenTimeout,
+		HalfOpenMaxCalls: DefaultHalfOpenMaxCalls,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = DefaultFailureThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = DefaultOpenTimeout
+	}
+	if c.HalfOpenMaxCalls <= 0 {
+		c.HalfOpenMaxCalls = DefaultHalfOpenMaxCalls
+	}
+	return c
+}
+
+// Breaker stops calls to a failing downstream so they fail fast instead of waiting for their timeout.
+// Every call let through by Allow must be followed by exactly one of Success, Failure or Ignore.
+// A Breaker is safe for concurrent use.
+type Breaker struct {
+	config Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	inFlight int
+}
+
+// New returns a closed Breaker using config
+func New(config Config) *Breaker {
+	return &Breaker{config: config.withDefaults(), now: time.Now}
+}
+
+// State returns the current state, an open circuit whose timeout has passed reports HalfOpen
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// advance moves an open circuit to half-open once the open timeout has passed
+func (b *Breaker) advance() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.state = HalfOpen
+		b.inFlight = 0
+	}
+}
+
+// Allow returns ErrOpen when the call must fail fast
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	switch b.state {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.inFlight >= b.config.HalfOpenMaxCalls {
+			return ErrOpen
+		}
+		b.inFlight++
+	}
+	return nil
+}
+
+// Success records a successful call, a successful trial call closes the circuit
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.state == HalfOpen {
+		b.state = Closed
+		b.inFlight = 0
+	}
+}
+
+// Failure records a failed call, which opens the circuit when it reaches the threshold or was a trial call
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.config.FailureThreshold) {
+		b.state = Open
+		b.openedAt = b.now()
+		b.inFlight = 0
+	}
+}
+
+// Ignore records a call whose outcome says nothing about the downstream, such as one cancelled by the caller
+func (b *Breaker) Ignore() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == HalfOpen && b.inFlight > 0 {
+		b.inFlight--
+	}
+}
@@ -0,0 +1,73 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := New(Config{FailureThreshold: 3, OpenTimeout: time.Minute, HalfOpenMaxCalls: 1})
+	b.now = func() time.Time { return now }
+
+	t.Run("Closed until the threshold", func(t *testing.T) {
+		b.Failure()
+		b.Failure()
+		b.Success()
+		b.Failure()
+		b.Failure()
+		if b.State() != Closed || b.Allow() != nil {
+			t.Fatalf("Expected a success to reset the failure count, got %s", b.State())
+		}
+		b.Failure()
+		if b.State() != Open {
+			t.Fatalf("Expected open, got %s", b.State())
+		}
+		if err := b.Allow(); !errors.Is(err, ErrOpen) {
+			t.Fatalf("Expected ErrOpen, got %v", err)
+		}
+	})
+
+	t.Run("Half-open limits trial calls", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		if b.State() != HalfOpen {
+			t.Fatalf("Expected half-open, got %s", b.State())
+		}
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Expected a trial call, got %v", err)
+		}
+		if err := b.Allow(); !errors.Is(err, ErrOpen) {
+			t.Fatalf("Expected a second trial call to be rejected, got %v", err)
+		}
+		b.Ignore()
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Expected an ignored trial call to free its slot, got %v", err)
+		}
+	})
+
+	t.Run("A failed trial opens the circuit again", func(t *testing.T) {
+		b.Failure()
+		if b.State() != Open {
+			t.Fatalf("Expected open, got %s", b.State())
+		}
+	})
+
+	t.Run("A successful trial closes the circuit", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Success()
+		if b.State() != Closed {
+			t.Fatalf("Expected closed, got %s", b.State())
+		}
+	})
+}
+
+func TestConfigDefaults(t *testing.T) {
+	b := New(Config{})
+	if b.config != DefaultConfig() {
+		t.Fatalf("Expected %+v, got %+v", DefaultConfig(), b.config)
+	}
+}
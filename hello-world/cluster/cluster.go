@@ -0,0 +1,117 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"hello-world/tracing"
+)
+
+// Default cache lifetimes, a missing tenant is retried sooner in case it is being provisioned
+const (
+	DefaultTTL         = 5 * time.Minute
+	DefaultNegativeTTL = 30 * time.Second
+)
+
+const tracerName = "hello-world/cluster"
+
+// ErrNoCluster is returned when a tenant is not assigned to any cluster
+var ErrNoCluster = errors.New("no cluster is assigned to tenant")
+
+// ServerInfo describes the VC cluster that serves a tenant
+type ServerInfo struct {
+	ClusterID string `json:"clusterId"`
+	// Endpoint is the gRPC target of the cluster, such as "vc-cluster-1.example.com:9884"
+	Endpoint string `json:"endpoint"`
+}
+
+// Source looks up the cluster of a tenant and business unit.
+// Implementations return an error wrapping ErrNoCluster when the tenant has no cluster.
+type Source interface {
+	Lookup(ctx context.Context, tenantID string, businessUnitID int32) (ServerInfo, error)
+}
+
+// noClusterError builds the error Sources return for a tenant without a cluster
+func noClusterError(tenantID string, businessUnitID int32) error {
+	return fmt.Errorf("%w: tenant %s business unit %d", ErrNoCluster, tenantID, businessUnitID)
+}
+
+type cacheKey struct {
+	tenantID       string
+	businessUnitID int32
+}
+
+type cacheEntry struct {
+	info      ServerInfo
+	err       error
+	expiresAt time.Time
+}
+
+// Resolver caches the lookups of a Source.
+// A Resolver kept in a package variable survives warm invocations, so most records never reach the Source.
+// Tenants without a cluster are cached as well, for the shorter negative TTL.
+// Other Source errors are not cached.
+type Resolver struct {
+	source      Source
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+// NewResolver returns a Resolver that caches source
+func NewResolver(source Source, ttl time.Duration, negativeTTL time.Duration) *Resolver {
+	return &Resolver{
+		source:      source,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		entries:     map[cacheKey]cacheEntry{},
+	}
+}
+
+// Resolve returns the cluster of a tenant and business unit
+func (r *Resolver) Resolve(ctx context.Context, tenantID string, businessUnitID int32) (info ServerInfo, err error) {
+	key := cacheKey{tenantID: strings.TrimSpace(tenantID), businessUnitID: businessUnitID}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "cluster.Resolve", trace.WithAttributes(
+		tracing.AttributeTenantID.String(key.tenantID),
+		attribute.Int("business_unit.id", int(key.businessUnitID)),
+	))
+	defer func() {
+		span.SetAttributes(attribute.String("cluster.id", info.ClusterID))
+		tracing.End(span, err)
+	}()
+
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	r.mu.Unlock()
+	if ok && r.now().Before(entry.expiresAt) {
+		span.SetAttributes(attribute.Bool("cluster.cache_hit", true))
+		return entry.info, entry.err
+	}
+	span.SetAttributes(attribute.Bool("cluster.cache_hit", false))
+
+	info, err = r.source.Lookup(ctx, key.tenantID, key.businessUnitID)
+	switch {
+	case err == nil:
+		entry = cacheEntry{info: info, expiresAt: r.now().Add(r.ttl)}
+	case errors.Is(err, ErrNoCluster):
+		entry = cacheEntry{err: err, expiresAt: r.now().Add(r.negativeTTL)}
+	default:
+		return ServerInfo{}, fmt.Errorf("failed to look up cluster for tenant %s: %w", key.tenantID, err)
+	}
+
+	r.mu.Lock()
+	r.entries[key] = entry
+	r.mu.Unlock()
+	return entry.info, entry.err
+}
@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type countingSource struct {
+	Source
+	lookups int
+	err     error
+}
+
+func (s *countingSource) Lookup(ctx context.Context, tenantID string, businessUnitID int32) (ServerInfo, error) {
+	s.lookups++
+	if s.err != nil {
+		return ServerInfo{}, s.err
+	}
+	return s.Source.Lookup(ctx, tenantID, businessUnitID)
+}
+
+func TestResolver(t *testing.T) {
+	source := &countingSource{Source: NewStaticSource([]Assignment{
+		{TenantID: "11", ServerInfo: ServerInfo{ClusterID: "c1", Endpoint: "c1:9884"}},
+		{TenantID: "11", BusinessUnitID: 7, ServerInfo: ServerInfo{ClusterID: "c2", Endpoint: "c2:9884"}},
+	}, nil)}
+	now := time.Now()
+	resolver := NewResolver(source, time.Minute, time.Second)
+	resolver.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	t.Run("Business unit wins over tenant", func(t *testing.T) {
+		info, err := resolver.Resolve(ctx, "11", 7)
+		if err != nil || info.ClusterID != "c2" {
+			t.Fatalf("Expected c2, got %+v %v", info, err)
+		}
+		info, err = resolver.Resolve(ctx, " 11 ", 1)
+		if err != nil || info.ClusterID != "c1" {
+			t.Fatalf("Expected c1, got %+v %v", info, err)
+		}
+	})
+
+	t.Run("Positive cache", func(t *testing.T) {
+		lookups := source.lookups
+		resolver.Resolve(ctx, "11", 1)
+		if source.lookups != lookups {
+			t.Fatal("Expected a cached lookup")
+		}
+		now = now.Add(2 * time.Minute)
+		resolver.Resolve(ctx, "11", 1)
+		if source.lookups != lookups+1 {
+			t.Fatal("Expected the expired entry to be looked up again")
+		}
+	})
+
+	t.Run("Negative cache", func(t *testing.T) {
+		lookups := source.lookups
+		for i := 0; i < 2; i++ {
+			_, err := resolver.Resolve(ctx, "99", 1)
+			if !errors.Is(err, ErrNoCluster) {
+				t.Fatalf("Expected ErrNoCluster, got %v", err)
+			}
+		}
+		if source.lookups != lookups+1 {
+			t.Fatalf("Expected a single lookup for a missing tenant, got %d", source.lookups-lookups)
+		}
+		now = now.Add(2 * time.Second)
+		resolver.Resolve(ctx, "99", 1)
+		if source.lookups != lookups+2 {
+			t.Fatal("Expected the negative entry to expire sooner than the positive TTL")
+		}
+	})
+
+	t.Run("Source errors are not cached", func(t *testing.T) {
+		source.err = errors.New("lookup service down")
+		defer func() { source.err = nil }()
+		lookups := source.lookups
+		for i := 0; i < 2; i++ {
+			if _, err := resolver.Resolve(ctx, "12", 1); err == nil || errors.Is(err, ErrNoCluster) {
+				t.Fatalf("Expected a lookup error, got %v", err)
+			}
+		}
+		if source.lookups != lookups+2 {
+			t.Fatal("Expected every failed lookup to reach the source")
+		}
+	})
+}
+
+func TestLoadStaticSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.json")
+	err := os.WriteFile(path, []byte(`[{"tenantId":"11","clusterId":"c1","endpoint":"c1:9884"}]`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := LoadStaticSource(path, &ServerInfo{ClusterID: "default", Endpoint: "default:9884"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := source.Lookup(context.Background(), "11", 1); info.ClusterID != "c1" {
+		t.Fatalf("Expected c1, got %+v", info)
+	}
+	if info, _ := source.Lookup(context.Background(), "12", 1); info.ClusterID != "default" {
+		t.Fatalf("Expected the fallback cluster, got %+v", info)
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenants/11/businessunits/4/cluster":
+			fmt.Fprint(w, `{"clusterId":"c1","endpoint":"c1:9884"}`)
+		case "/tenants/12/businessunits/4/cluster":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	source := NewHTTPSource(server.URL+"/", time.Second)
+
+	info, err := source.Lookup(context.Background(), "11", 4)
+	if err != nil || info.Endpoint != "c1:9884" {
+		t.Fatalf("Expected c1:9884, got %+v %v", info, err)
+	}
+	if _, err := source.Lookup(context.Background(), "99", 4); !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("Expected ErrNoCluster, got %v", err)
+	}
+	if _, err := source.Lookup(context.Background(), "12", 4); err == nil || errors.Is(err, ErrNoCluster) {
+		t.Fatalf("Expected a lookup error, got %v", err)
+	}
+}
+
+// fakeDynamoDB is an in-memory stand-in for a tenant cluster map keyed by tenantId and businessUnitId
+type fakeDynamoDB struct {
+	items map[string]map[string]types.AttributeValue
+	err   error
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	tenantID := params.Key[attributeTenantID].(*types.AttributeValueMemberS).Value
+	businessUnitID := params.Key[attributeBusinessUnitID].(*types.AttributeValueMemberN).Value
+	return &dynamodb.GetItemOutput{Item: f.items[aws.ToString(params.TableName)+"/"+tenantID+"/"+businessUnitID]}, nil
+}
+
+func assignmentItem(clusterID string, endpoint string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attributeClusterID: &types.AttributeValueMemberS{Value: clusterID},
+		attributeEndpoint:  &types.AttributeValueMemberS{Value: endpoint},
+	}
+}
+
+func TestDynamoDBSource(t *testing.T) {
+	client := &fakeDynamoDB{items: map[string]map[string]types.AttributeValue{
+		"clusters/11/0": assignmentItem("c1", "c1:9884"),
+		"clusters/11/4": assignmentItem("c2", "c2:9884"),
+	}}
+	source := NewDynamoDBSource(client, "clusters", nil)
+	ctx := context.Background()
+
+	if info, err := source.Lookup(ctx, "11", 4); err != nil || info.ClusterID != "c2" {
+		t.Fatalf("Expected the business unit assignment c2, got %+v %v", info, err)
+	}
+	if info, err := source.Lookup(ctx, "11", 5); err != nil || info.ClusterID != "c1" {
+		t.Fatalf("Expected the tenant assignment c1, got %+v %v", info, err)
+	}
+	if _, err := source.Lookup(ctx, "99", 4); !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("Expected ErrNoCluster, got %v", err)
+	}
+
+	fallback := NewDynamoDBSource(client, "clusters", &ServerInfo{ClusterID: "default", Endpoint: "default:9884"})
+	if info, _ := fallback.Lookup(ctx, "99", 4); info.ClusterID != "default" {
+		t.Fatalf("Expected the fallback cluster, got %+v", info)
+	}
+
+	client.err = errors.New("throttled")
+	if _, err := source.Lookup(ctx, "11", 4); err == nil || errors.Is(err, ErrNoCluster) {
+		t.Fatalf("Expected a lookup error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attribute names of the DynamoDB tenant cluster map.
+// The table must have tenantId as its partition key and businessUnitId as its sort key,
+// where a businessUnitId of 0 assigns every business unit of the tenant.
+const (
+	attributeTenantID       = "tenantId"
+	attributeBusinessUnitID = "businessUnitId"
+	attributeClusterID      = "clusterId"
+	attributeEndpoint       = "endpoint"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB client used by DynamoDBSource
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+}
+
+// DynamoDBSource looks up clusters in a DynamoDB table of assignments, the tenant cluster map
+type DynamoDBSource struct {
+	client DynamoDBAPI
+	table  string
+	// fallback is used for tenants without an assignment when it is not nil
+	fallback *ServerInfo
+}
+
+// NewDynamoDBSource returns a DynamoDBSource that reads table through client.
+// When fallback is not nil every unassigned tenant resolves to it.
+func NewDynamoDBSource(client DynamoDBAPI, table string, fallback *ServerInfo) *DynamoDBSource {
+	return &DynamoDBSource{client: client, table: table, fallback: fallback}
+}
+
+// NewDynamoDBClient loads the default AWS configuration and returns a DynamoDB client.
+// If endpoint is not empty every request is sent there instead, which allows DynamoDB Local to be used.
+func NewDynamoDBClient(ctx context.Context, endpoint string) (*dynamodb.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+// Lookup implements Source.
+// A business unit assignment wins over the assignment of its tenant.
+func (s *DynamoDBSource) Lookup(ctx context.Context, tenantID string, businessUnitID int32) (ServerInfo, error) {
+	units := []int32{businessUnitID}
+	if businessUnitID != 0 {
+		units = append(units, 0)
+	}
+	for _, unit := range units {
+		info, ok, err := s.get(ctx, tenantID, unit)
+		if err != nil {
+			return ServerInfo{}, err
+		}
+		if ok {
+			return info, nil
+		}
+	}
+	if s.fallback != nil {
+		return *s.fallback, nil
+	}
+	return ServerInfo{}, noClusterError(tenantID, businessUnitID)
+}
+
+// get reads the assignment of one tenant and business unit, reporting false when there is none
+func (s *DynamoDBSource) get(ctx context.Context, tenantID string, businessUnitID int32) (ServerInfo, bool, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]types.AttributeValue{
+			attributeTenantID:       &types.AttributeValueMemberS{Value: tenantID},
+			attributeBusinessUnitID: &types.AttributeValueMemberN{Value: strconv.Itoa(int(businessUnitID))},
+		},
+	})
+	if err != nil {
+		return ServerInfo{}, false, fmt.Errorf("failed to read tenant cluster map %s: %w", s.table, err)
+	}
+	info := ServerInfo{
+		ClusterID: stringAttribute(output.Item, attributeClusterID),
+		Endpoint:  stringAttribute(output.Item, attributeEndpoint),
+	}
+	return info, info.Endpoint != "", nil
+}
+
+func stringAttribute(item map[string]types.AttributeValue, name string) string {
+	if value, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return value.Value
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPSource looks up clusters with a lookup service.
+// The service answers GET {baseURL}/tenants/{tenantId}/businessunits/{businessUnitId}/cluster
+// with a JSON ServerInfo, or 404 when the tenant has no cluster.
+type HTTPSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPSource returns an HTTPSource for the lookup service at baseURL
+func NewHTTPSource(baseURL string, timeout time.Duration) *HTTPSource {
+	return &HTTPSource{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Lookup implements Source
+func (s *HTTPSource) Lookup(ctx context.Context, tenantID string, businessUnitID int32) (ServerInfo, error) {
+	lookupURL := fmt.Sprintf("%s/tenants/%s/businessunits/%d/cluster", s.baseURL, url.PathEscape(tenantID), businessUnitID)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, nil)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return ServerInfo{}, fmt.Errorf("cluster lookup request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ServerInfo{}, noClusterError(tenantID, businessUnitID)
+	default:
+		return ServerInfo{}, fmt.Errorf("cluster lookup returned status %d", response.StatusCode)
+	}
+
+	var info ServerInfo
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return ServerInfo{}, fmt.Errorf("failed to decode cluster lookup response: %w", err)
+	}
+	if info.Endpoint == "" {
+		return ServerInfo{}, noClusterError(tenantID, businessUnitID)
+	}
+	return info, nil
+}
@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Assignment assigns a tenant, or one of its business units, to a cluster
+type Assignment struct {
+	TenantID string `json:"tenantId"`
+	// BusinessUnitID is optional, an Assignment without one covers every business unit of the tenant
+	BusinessUnitID int32 `json:"businessUnitId,omitempty"`
+	ServerInfo
+}
+
+// StaticSource looks up clusters in a fixed list of assignments
+type StaticSource struct {
+	assignments map[cacheKey]ServerInfo
+	// fallback is used for tenants without an assignment when it is not nil
+	fallback *ServerInfo
+}
+
+// NewStaticSource returns a StaticSource for assignments.
+// When fallback is not nil every unassigned tenant resolves to it.
+func NewStaticSource(assignments []Assignment, fallback *ServerInfo) *StaticSource {
+	s := &StaticSource{assignments: map[cacheKey]ServerInfo{}, fallback: fallback}
+	for _, a := range assignments {
+		s.assignments[cacheKey{tenantID: a.TenantID, businessUnitID: a.BusinessUnitID}] = a.ServerInfo
+	}
+	return s
+}
+
+// LoadStaticSource reads assignments from a JSON file holding an array of Assignment
+func LoadStaticSource(path string, fallback *ServerInfo) (*StaticSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster map %s: %w", path, err)
+	}
+	var assignments []Assignment
+	if err := json.Unmarshal(data, &assignments); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster map %s: %w", path, err)
+	}
+	return NewStaticSource(assignments, fallback), nil
+}
+
+// Lookup implements Source.
+// A business unit assignment wins over the assignment of its tenant.
+func (s *StaticSource) Lookup(_ context.Context, tenantID string, businessUnitID int32) (ServerInfo, error) {
+	if info, ok := s.assignments[cacheKey{tenantID: tenantID, businessUnitID: businessUnitID}]; ok {
+		return info, nil
+	}
+	if info, ok := s.assignments[cacheKey{tenantID: tenantID}]; ok {
+		return info, nil
+	}
+	if s.fallback != nil {
+		return *s.fallback, nil
+	}
+	return ServerInfo{}, noClusterError(tenantID, businessUnitID)
+}
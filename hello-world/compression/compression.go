@@ -0,0 +1,98 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format is how a payload is compressed
+type Format int
+
+// Acceptable `Format` values
+const (
+	// None is a payload that is not compressed
+	None Format = iota
+	Gzip
+	Zstd
+)
+
+var format_name = map[Format]string{
+	None: "none",
+	Gzip: "gzip",
+	Zstd: "zstd",
+}
+
+func (f Format) String() string {
+	return format_name[f]
+}
+
+// DefaultMaxSize is the largest payload Decompress produces when no limit is configured
+const DefaultMaxSize = 10 << 20
+
+// ErrTooLarge is returned when a payload decompresses to more than the maximum size
+var ErrTooLarge = errors.New("decompressed payload is too large")
+
+var (
+	gzipMagic = []byte{0x1F, 0x8B}
+	zstdMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+)
+
+// Detect identifies the compression of data by its magic bytes
+func Detect(data []byte) Format {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return Gzip
+	case bytes.HasPrefix(data, zstdMagic):
+		return Zstd
+	default:
+		return None
+	}
+}
+
+// Decompress returns data decompressed according to its magic bytes, or data itself when it is not compressed.
+// A payload that decompresses to more than maxSize bytes is rejected with ErrTooLarge before it is fully read,
+// which guards against decompression bombs. A maxSize of zero or less uses DefaultMaxSize.
+func Decompress(data []byte, maxSize int64) ([]byte, Format, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	format := Detect(data)
+	var r io.Reader
+	switch format {
+	case Gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, format, fmt.Errorf("invalid gzip payload: %w", err)
+		}
+		defer gr.Close()
+		r = gr
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+		if err != nil {
+			return nil, format, fmt.Errorf("invalid zstd payload: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return data, None, nil
+	}
+
+	// Reading one byte past the limit tells a payload at the limit apart from one over it
+	decompressed, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			return nil, format, fmt.Errorf("%w: over %d bytes", ErrTooLarge, maxSize)
+		}
+		return nil, format, fmt.Errorf("invalid %s payload: %w", format, err)
+	}
+	if int64(len(decompressed)) > maxSize {
+		return nil, format, fmt.Errorf("%w: over %d bytes", ErrTooLarge, maxSize)
+	}
+	return decompressed, format, nil
+}
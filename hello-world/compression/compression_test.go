@@ -0,0 +1,71 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func zstded(s string) []byte {
+	w, _ := zstd.NewWriter(nil)
+	defer w.Close()
+	return w.EncodeAll([]byte(s), nil)
+}
+
+func TestDecompress(t *testing.T) {
+	payload := `{"eventId":"e1"}`
+	for _, test := range []struct {
+		name   string
+		data   []byte
+		format Format
+	}{
+		{"Plain", []byte(payload), None},
+		{"Gzip", gzipped(payload), Gzip},
+		{"Zstd", zstded(payload), Zstd},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, format, err := Decompress(test.data, 0)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if format != test.format || string(data) != payload {
+				t.Fatalf("Expected %s %s, got %s %s", test.format, payload, format, data)
+			}
+		})
+	}
+
+	t.Run("Decompression bomb", func(t *testing.T) {
+		bomb := strings.Repeat("a", 1<<20)
+		for _, data := range [][]byte{gzipped(bomb), zstded(bomb)} {
+			if _, _, err := Decompress(data, 1<<10); !errors.Is(err, ErrTooLarge) {
+				t.Fatalf("Expected ErrTooLarge, got %v", err)
+			}
+		}
+	})
+
+	t.Run("Payload at the limit", func(t *testing.T) {
+		if _, _, err := Decompress(gzipped(payload), int64(len(payload))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Corrupt payload", func(t *testing.T) {
+		data := gzipped(payload)
+		data = data[:len(data)-6]
+		if _, format, err := Decompress(data, 0); err == nil || errors.Is(err, ErrTooLarge) || format != Gzip {
+			t.Fatalf("Expected a gzip error, got %s %v", format, err)
+		}
+	})
+}
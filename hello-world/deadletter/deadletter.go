@@ -0,0 +1,85 @@
+package deadletter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Reason describes why a record was dead-lettered
+type Reason string
+
+// Acceptable `Reason` values
+const (
+	// ReasonDecodeFailure is used when the record data is not a valid StreamEventRequest
+	ReasonDecodeFailure Reason = "DecodeFailure"
+	// ReasonPayloadTooLarge is used when the record data decompresses to more than the maximum size
+	ReasonPayloadTooLarge Reason = "PayloadTooLarge"
+	// ReasonValidationFailure is used when the StreamEventRequest decoded but is missing required values
+	ReasonValidationFailure Reason = "ValidationFailure"
+	// ReasonUnhandledEvent is used when no handler is registered for the event and the dispatch policy dead-letters it
+	ReasonUnhandledEvent Reason = "UnhandledEvent"
+	// ReasonNoCluster is used when the tenant of the event is still not assigned to any cluster once the retry budget is spent
+	ReasonNoCluster Reason = "NoCluster"
+	// ReasonDenied is used when a rule denies the event and its action dead-letters it
+	ReasonDenied Reason = "Denied"
+	// ReasonStale is used when the event is older than the staleness threshold and the stale policy dead-letters it
+	ReasonStale Reason = "Stale"
+	// ReasonRetryBudgetExceeded is used when a retryable record has failed more times than allowed
+	ReasonRetryBudgetExceeded Reason = "RetryBudgetExceeded"
+	// ReasonPermanentFailure is used for any other failure that was classified as permanent
+	ReasonPermanentFailure Reason = "PermanentFailure"
+)
+
+// Entry is a poison record along with everything needed to inspect or replay it later
+// The user records of a KPL aggregated record have a SequenceNumber of "<sequence number>:<sub-sequence number>"
+// and the events of an envelope have "<sequence number>/<event index>".
+// Records from SQS keep their queue ARN in ShardID, message ID in SequenceNumber and message group ID in PartitionKey.
+// Records from DynamoDB Streams keep their stream ARN in ShardID and item key in PartitionKey,
+// and records from Kafka keep their topic partition in ShardID and PartitionKey and topic partition and offset in SequenceNumber.
+// A sink that cannot hold the record data drops Data and keeps its length and hex encoded SHA-256 digest instead.
+type Entry struct {
+	EventSource    string    `json:"eventSource,omitempty"`
+	Data           []byte    `json:"data"`
+	DataLength     int       `json:"dataLength,omitempty"`
+	DataSHA256     string    `json:"dataSha256,omitempty"`
+	ShardID        string    `json:"shardId"`
+	SequenceNumber string    `json:"sequenceNumber"`
+	PartitionKey   string    `json:"partitionKey"`
+	Reason         Reason    `json:"reason"`
+	Error          string    `json:"error"`
+	Attempts       int       `json:"attempts"`
+	FailedAt       time.Time `json:"failedAt"`
+}
+
+// Sink stores dead-lettered records.
+// Implementations must be safe for concurrent use.
+type Sink interface {
+	Send(ctx context.Context, entry Entry) error
+}
+
+// MemorySink keeps dead-lettered records in memory and is intended for tests
+type MemorySink struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemorySink returns an empty MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Send implements Sink
+func (s *MemorySink) Send(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// Entries returns a copy of every entry sent so far
+func (s *MemorySink) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
@@ -0,0 +1,115 @@
+package deadletter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+var testEntry = Entry{
+	Data:           []byte(`{"eventId":"a"}`),
+	ShardID:        "shardId-000000000001",
+	SequenceNumber: "42",
+	PartitionKey:   "case-1",
+	Reason:         ReasonValidationFailure,
+	Error:          "missing case id",
+	Attempts:       1,
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := sink.Send(context.Background(), testEntry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || string(entries[1].Data) != string(testEntry.Data) || entries[1].Reason != testEntry.Reason {
+		t.Fatalf("Unexpected entries %+v", entries)
+	}
+}
+
+type fakeSQS struct {
+	inputs []*sqs.SendMessageInput
+}
+
+func (f *fakeSQS) SendMessage(_ context.Context, params *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &sqs.SendMessageOutput{MessageId: aws.String("1")}, nil
+}
+
+func TestSQSSink(t *testing.T) {
+	client := &fakeSQS{}
+	sink := NewSQSSink(client, "http://localhost:9324/000000000000/deadletter")
+
+	if err := sink.Send(context.Background(), testEntry); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.inputs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(client.inputs))
+	}
+
+	input := client.inputs[0]
+	if aws.ToString(input.QueueUrl) != "http://localhost:9324/000000000000/deadletter" {
+		t.Fatalf("Unexpected queue url %s", aws.ToString(input.QueueUrl))
+	}
+	if aws.ToString(input.MessageAttributes["Reason"].StringValue) != string(ReasonValidationFailure) {
+		t.Fatalf("Unexpected reason attribute %+v", input.MessageAttributes["Reason"])
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(aws.ToString(input.MessageBody)), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.SequenceNumber != "42" || entry.PartitionKey != "case-1" {
+		t.Fatalf("Unexpected message body %+v", entry)
+	}
+}
+
+func TestSQSSinkOversizedEntry(t *testing.T) {
+	client := &fakeSQS{}
+	sink := NewSQSSink(client, "http://localhost:9324/000000000000/deadletter")
+
+	oversized := testEntry
+	oversized.Data = bytes.Repeat([]byte("a"), MaxSQSMessageSize)
+	if err := sink.Send(context.Background(), oversized); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.inputs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(client.inputs))
+	}
+
+	body := aws.ToString(client.inputs[0].MessageBody)
+	if len(body) > MaxSQSMessageSize {
+		t.Fatalf("Expected a message body of at most %d bytes, got %d", MaxSQSMessageSize, len(body))
+	}
+	var entry Entry
+	if err := json.Unmarshal([]byte(body), &entry); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(oversized.Data)
+	if entry.Data != nil || entry.DataLength != MaxSQSMessageSize || entry.DataSHA256 != hex.EncodeToString(digest[:]) {
+		t.Fatalf("Expected the data to be replaced by its length and digest, got length %d digest %q", entry.DataLength, entry.DataSHA256)
+	}
+	if entry.SequenceNumber != "42" || entry.Reason != ReasonValidationFailure {
+		t.Fatalf("Unexpected message body %+v", entry)
+	}
+}
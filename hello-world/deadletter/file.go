@@ -0,0 +1,66 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSink appends dead-lettered records to a local file as newline delimited JSON.
+// It is intended for tests and local runs, a Lambda filesystem does not outlive the execution environment.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens path for appending, creating it if it does not exist
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead letter file %s: %w", path, err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Send implements Sink
+func (s *FileSink) Send(_ context.Context, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write dead letter entry: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// ReadFile returns every entry written to the dead letter file at path
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead letter file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var entry Entry
+		if err := decoder.Decode(&entry); err != nil {
+			return entries, fmt.Errorf("failed to decode dead letter entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
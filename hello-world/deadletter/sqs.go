@@ -0,0 +1,121 @@
+package deadletter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// MaxSQSMessageSize is the largest message SQS accepts, counting the body and message attributes
+const MaxSQSMessageSize = 256 << 10
+
+// maxSQSErrorLength bounds the error kept in an entry whose data did not fit in a message
+const maxSQSErrorLength = 4 << 10
+
+// SQSAPI is the subset of the SQS client used by SQSSink
+type SQSAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// SQSSink sends dead-lettered records to an SQS queue.
+// The message body is the JSON encoded Entry, and the reason, shard ID and sequence number
+// are copied into message attributes so the queue can be filtered without decoding the body.
+// An entry too large for a message is sent without its Data, keeping only the length and digest of the data.
+type SQSSink struct {
+	client   SQSAPI
+	queueURL string
+}
+
+// NewSQSSink returns an SQSSink that sends to queueURL using client
+func NewSQSSink(client SQSAPI, queueURL string) *SQSSink {
+	return &SQSSink{client: client, queueURL: queueURL}
+}
+
+// NewSQSClient loads the default AWS configuration and returns an SQS client.
+// If endpoint is not empty every request is sent there instead, which allows a local SQS stand-in to be used.
+func NewSQSClient(ctx context.Context, endpoint string) (*sqs.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	return sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+// Send implements Sink
+func (s *SQSSink) Send(ctx context.Context, entry Entry) error {
+	attributes := map[string]types.MessageAttributeValue{
+		"Reason":         stringAttribute(string(entry.Reason)),
+		"ShardID":        stringAttribute(entry.ShardID),
+		"SequenceNumber": stringAttribute(entry.SequenceNumber),
+		"Attempts": {
+			DataType:    aws.String("Number"),
+			StringValue: aws.String(strconv.Itoa(entry.Attempts)),
+		},
+	}
+
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter entry: %w", err)
+	}
+	// Retrying an entry SQS rejects for its size would block the shard, so the data is replaced by its digest
+	if len(body)+attributesSize(attributes) > MaxSQSMessageSize {
+		body, err = json.Marshal(withoutData(entry))
+		if err != nil {
+			return fmt.Errorf("failed to marshal dead letter entry: %w", err)
+		}
+	}
+
+	_, err = s.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(s.queueURL),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: attributes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send dead letter entry to %s: %w", s.queueURL, err)
+	}
+	return nil
+}
+
+// withoutData returns entry with Data replaced by its length and SHA-256 digest and the error shortened
+func withoutData(entry Entry) Entry {
+	digest := sha256.Sum256(entry.Data)
+	entry.DataLength = len(entry.Data)
+	entry.DataSHA256 = hex.EncodeToString(digest[:])
+	entry.Data = nil
+	if len(entry.Error) > maxSQSErrorLength {
+		entry.Error = entry.Error[:maxSQSErrorLength]
+	}
+	return entry
+}
+
+// attributesSize counts the message attributes the way SQS does, by the length of every name, data type and value
+func attributesSize(attributes map[string]types.MessageAttributeValue) int {
+	size := 0
+	for name, value := range attributes {
+		size += len(name) + len(aws.ToString(value.DataType)) + len(aws.ToString(value.StringValue))
+	}
+	return size
+}
+
+func stringAttribute(value string) types.MessageAttributeValue {
+	// SQS rejects empty attribute values
+	if value == "" {
+		value = "unknown"
+	}
+	return types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"hello-world/batch"
+	"hello-world/compression"
+	"hello-world/metrics"
+	"hello-world/source"
+)
+
+// maxDecompressedSize is the largest payload a compressed record may expand to, it is set by MaxDecompressedSize
+var maxDecompressedSize int64 = compression.DefaultMaxSize
+
+// checkDecompressed fails a record that unpackRecords could not decompress and fills in the sizes of one it did.
+// A payload that cannot be decompressed will never succeed, so it is dead-lettered.
+func checkDecompressed(ctx context.Context, record source.Record, summary *recordSummary) error {
+	if err := record.DecompressErr; err != nil {
+		loggerFrom(ctx).Error("Failed to decompress record", zap.Stringer("compression", record.Compression), zap.Error(err))
+		if errors.Is(err, compression.ErrTooLarge) {
+			return batch.Permanent(err)
+		}
+		return batch.Permanent(fmt.Errorf("%w: %w", errDecodeFailure, err))
+	}
+	if record.Compression != compression.None {
+		summary.compressedSize, summary.decompressedSize = record.CompressedSize, record.DecompressedSize
+	}
+	return nil
+}
+
+// reportCompression records the compression ratio of every compressed record
+func reportCompression(recorder *metrics.Recorder, summaries []recordSummary) {
+	for i := range summaries {
+		summary := &summaries[i]
+		if summary.compressedSize > 0 {
+			ratio := float64(summary.decompressedSize) / float64(summary.compressedSize)
+			recorder.Value(metrics.CompressionRatio, summary.dimensions(""), ratio)
+		}
+	}
+}
+
+// loadMaxDecompressedSize reads MaxDecompressedSize, in bytes, from the environment
+func loadMaxDecompressedSize() (int64, error) {
+	size, err := intFromEnv("MaxDecompressedSize", compression.DefaultMaxSize)
+	return int64(size), err
+}
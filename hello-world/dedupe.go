@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"hello-world/digimodel"
+	"hello-world/idempotency"
+	"hello-world/source"
+)
+
+var (
+	// idempotencyStore remembers events whose handler completed, so redelivered records do not repeat side effects
+	idempotencyStore idempotency.Store = idempotency.NewMemoryStore()
+
+	// idempotencyTTL is how long a completed event is remembered
+	idempotencyTTL = idempotency.DefaultTTL
+)
+
+// alreadyCompleted reports whether event was processed to completion by an earlier delivery.
+// A store failure is logged and treated as not completed, which falls back to at least once delivery.
+func alreadyCompleted(ctx context.Context, event *digimodel.StreamEventRequest) bool {
+	if event.EventID == "" {
+		return false
+	}
+	completed, err := idempotencyStore.Completed(ctx, event.EventID)
+	if err != nil {
+		loggerFrom(ctx).Warn("Failed to check whether event was already processed", zap.Error(err))
+		return false
+	}
+	return completed
+}
+
+// markCompleted remembers event as processed.
+// The side effects already happened, so a store failure is only logged rather than failing the record.
+func markCompleted(ctx context.Context, event *digimodel.StreamEventRequest) {
+	if event.EventID == "" {
+		return
+	}
+	err := idempotencyStore.MarkCompleted(ctx, event.EventID, idempotencyTTL)
+	if err != nil {
+		loggerFrom(ctx).Warn("Failed to mark event as processed", zap.Error(err))
+	}
+}
+
+// subRecordKey is the idempotency key of a user record of a KPL aggregated record or an event of an envelope.
+// Sequence numbers are only unique within a stream, so the key includes the shard.
+func subRecordKey(record source.Record) string {
+	return "subrecord:" + record.Partition + ":" + record.UniqueID()
+}
+
+// subRecordCompleted reports whether record is part of a larger record, a user record of a KPL aggregated record
+// or an event of an envelope, and succeeded in an earlier delivery.
+// The larger record is retried as a whole when any of its parts fails.
+func subRecordCompleted(ctx context.Context, record source.Record) bool {
+	if !record.Shared() {
+		return false
+	}
+	completed, err := idempotencyStore.Completed(ctx, subRecordKey(record))
+	if err != nil {
+		loggerFrom(ctx).Warn("Failed to check whether part of a record was already processed", zap.Error(err))
+		return false
+	}
+	return completed
+}
+
+// markSubRecordCompleted remembers that record, part of a larger record, succeeded
+func markSubRecordCompleted(ctx context.Context, record source.Record) {
+	if !record.Shared() {
+		return
+	}
+	err := idempotencyStore.MarkCompleted(ctx, subRecordKey(record), idempotencyTTL)
+	if err != nil {
+		loggerFrom(ctx).Warn("Failed to mark part of a record as processed", zap.Error(err))
+	}
+}
+
+// newIdempotencyStore builds the store configured by the environment.
+// IdempotencyTable selects DynamoDB, optionally sent to IdempotencyEndpoint for DynamoDB Local,
+// and IdempotencyFile selects an embedded key value file. With neither set completed events are kept in memory.
+func newIdempotencyStore(ctx context.Context) (idempotency.Store, error) {
+	if table := os.Getenv("IdempotencyTable"); table != "" {
+		client, err := idempotency.NewDynamoDBClient(ctx, os.Getenv("IdempotencyEndpoint"))
+		if err != nil {
+			return nil, err
+		}
+		return idempotency.NewDynamoDBStore(client, table), nil
+	}
+	if path := os.Getenv("IdempotencyFile"); path != "" {
+		return idempotency.NewBoltStore(path)
+	}
+	return idempotency.NewMemoryStore(), nil
+}
+
+// loadIdempotencyTTL reads IdempotencyTTL from the environment as a duration such as "48h"
+func loadIdempotencyTTL() (time.Duration, error) {
+	return durationFromEnv("IdempotencyTTL", idempotency.DefaultTTL)
+}
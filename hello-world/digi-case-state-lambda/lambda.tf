@@ -62,8 +62,38 @@ resource "aws_lambda_function" "case_state_lambda" {
       AwsRegion = var.aws_region
       Environment = var.aws-region-id
       DebugLogging = var.lambda-debug-logging
+      LogLevel = var.lambda-log-level
+      LogEncoding = var.lambda-log-encoding
+      LogRawRecords = var.lambda-log-raw-records
+      RedactionPolicy = var.redaction-policy
+      RedactionHashKey = var.redaction-hash-key
       TenantClusterMap = "${var.resource-prefix}-${var.tenant-cluster-map}-${var.aws-region-id}"
       TestStreamOut = var.test-stream-output == "" ? "" : "${var.resource-prefix}-${var.aws-region-id}-${var.test-stream-output}"
+      BatchFailureMode = var.batch-failure-mode
+      DeadLetterQueueUrl = var.dead-letter-queue-url
+      MaxRecordAttempts = var.max-record-attempts
+      DeadlineSafetyMargin = var.deadline-safety-margin
+      RecordConcurrency = var.record-concurrency
+      RecordOrderingKey = var.record-ordering-key
+      UnregisteredEventPolicy = var.unregistered-event-policy
+      IdempotencyTable = var.idempotency-table
+      IdempotencyTTL = var.idempotency-ttl
+      VcGrpcTarget = var.vc-grpc-target
+      ClusterLookupUrl = var.cluster-lookup-url
+      ClusterCacheTTL = var.cluster-cache-ttl
+      ClusterNegativeCacheTTL = var.cluster-negative-cache-ttl
+      CircuitFailureThreshold = var.circuit-failure-threshold
+      CircuitOpenTimeout = var.circuit-open-timeout
+      CircuitHalfOpenMaxCalls = var.circuit-half-open-max-calls
+      RecordRules = var.record-rules
+      MetricNamespace = var.metric-namespace
+      MetricDimensions = var.metric-dimensions
+      TracingExporter = var.tracing-exporter
+      StaleEventThreshold = var.stale-event-threshold
+      StaleEventPolicy = var.stale-event-policy
+      EventSource = var.event-source
+      DynamoDBImageAttribute = var.dynamodb-image-attribute
+      MaxDecompressedSize = var.max-decompressed-size
     }
   }
 
@@ -95,6 +125,7 @@ resource "aws_lambda_event_source_mapping" "case_state_lambda_event_source_mappi
   parallelization_factor = 2
   starting_position = "LATEST"
   maximum_retry_attempts = 3
+  function_response_types = ["ReportBatchItemFailures"]
   filter_criteria {
     [
       {
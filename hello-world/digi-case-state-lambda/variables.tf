@@ -15,12 +15,139 @@ variable "vc-grpc-port" {
   type        = number
 }
 
+variable "vc-grpc-target" {
+  default     = ""
+  description = "The host:port of the VC that receives case status changes via GRPC for tenants the tenant cluster map does not assign."
+  type        = string
+}
+
+variable "cluster-lookup-url" {
+  default     = ""
+  description = "The base URL of the service that assigns tenants and business units to VC clusters. When empty the tenant cluster map is used."
+  type        = string
+}
+
+variable "cluster-cache-ttl" {
+  default     = "5m"
+  description = "How long a resolved cluster assignment is cached by a warm lambda."
+  type        = string
+}
+
+variable "cluster-negative-cache-ttl" {
+  default     = "30s"
+  description = "How long a tenant without a cluster assignment is remembered before it is looked up again."
+  type        = string
+}
+
+variable "circuit-failure-threshold" {
+  default     = 5
+  description = "The number of consecutive failed sends that opens the circuit of a VC cluster so its records fail fast."
+  type        = number
+}
+
+variable "circuit-open-timeout" {
+  default     = "30s"
+  description = "How long the circuit of a VC cluster stays open before a trial send is let through."
+  type        = string
+}
+
+variable "circuit-half-open-max-calls" {
+  default     = 1
+  description = "The number of trial sends allowed at the same time while the circuit of a VC cluster is half-open."
+  type        = number
+}
+
+variable "record-rules" {
+  default     = ""
+  description = "JSON rules that allow or deny tenants, business units, event types and case statuses with an action of skip, fail or deadletter. When empty events of tenant 0 fail."
+  type        = string
+}
+
+variable "metric-namespace" {
+  default     = "DigiCaseState"
+  description = "The CloudWatch namespace of the metrics the lambda emits in embedded metric format."
+  type        = string
+}
+
+variable "metric-dimensions" {
+  default     = "EventObject,EventType;Reason;"
+  description = "The dimension sets of the lambda metrics. Sets are separated by semicolons and dimensions by commas, an empty set aggregates every record."
+  type        = string
+}
+
+variable "tracing-exporter" {
+  default     = "none"
+  description = "Where the lambda sends trace spans, either none or stdout. With none the spans are dropped, as no exporter sends them to X-Ray."
+  type        = string
+}
+
+variable "stale-event-threshold" {
+  default     = "0s"
+  description = "Age after which an event is stale and follows the stale event policy. 0s disables the check."
+  type        = string
+}
+
+variable "stale-event-policy" {
+  default     = "process"
+  description = "What happens to stale events, one of process, skip or deadletter."
+  type        = string
+}
+
+variable "event-source" {
+  default     = "kinesis"
+  description = "Which service invokes the lambda, one of kinesis, sqs, dynamodb, kafka or firehose."
+  type        = string
+}
+
+variable "dynamodb-image-attribute" {
+  default     = "event"
+  description = "The NewImage attribute holding the event when the event source is dynamodb."
+  type        = string
+}
+
+variable "max-decompressed-size" {
+  default     = 10485760
+  description = "The largest size in bytes a gzip or zstd compressed Kinesis record may decompress to before it is dead-lettered. Records of other event sources are not decompressed."
+  type        = number
+}
+
 variable "lambda-debug-logging" {
   default     = false
   description = "This will enable or disable debug level logging within the lambda function code."
   type        = bool
 }
 
+variable "lambda-log-level" {
+  default     = ""
+  description = "The minimum level logged by the lambda function code, such as debug, info or warn. When empty lambda-debug-logging decides between debug and info."
+  type        = string
+}
+
+variable "lambda-log-encoding" {
+  default     = "json"
+  description = "The encoding of lambda function log lines, either json or console."
+  type        = string
+}
+
+variable "lambda-log-raw-records" {
+  default     = false
+  description = "This will log the raw payload of every record at debug level, with personal data redacted."
+  type        = bool
+}
+
+variable "redaction-policy" {
+  default     = ""
+  description = "Overrides how personal data is redacted from logs, such as User.EmailAddress=mask,Case.DetailUrl=hash."
+  type        = string
+}
+
+variable "redaction-hash-key" {
+  default     = ""
+  description = "The key used to hash redacted values so equal values can be correlated in logs."
+  type        = string
+  sensitive   = true
+}
+
 variable "lambda-memory-megabytes" {
   default     = 512
   description = "The number of memory megabytes that will be allocated to the lambda function."
@@ -45,8 +172,62 @@ variable "zip_file_name" {
   type = string
 }
 
+variable "batch-failure-mode" {
+  default     = "continue"
+  description = "How the lambda reacts to a failed record: \"continue\" reports only failed records, \"stop\" reports the first failed record and every record after it."
+  type        = string
+}
+
+variable "dead-letter-queue-url" {
+  default     = ""
+  description = "The URL of the SQS queue that receives records which can never be processed. When empty those records are only logged."
+  type        = string
+}
+
+variable "max-record-attempts" {
+  default     = 0
+  description = "The number of failed attempts after which a record is dead-lettered instead of retried. 0 means unlimited."
+  type        = number
+}
+
+variable "deadline-safety-margin" {
+  default     = "500ms"
+  description = "The time kept in reserve before the lambda timeout to report unprocessed records for retry instead of timing out."
+  type        = string
+}
+
+variable "record-concurrency" {
+  default     = 1
+  description = "The number of record ordering keys processed at the same time within a batch. 1 processes the batch sequentially."
+  type        = number
+}
+
+variable "record-ordering-key" {
+  default     = "partitionKey"
+  description = "The key records are processed in order by when record-concurrency is above 1, either \"partitionKey\" or \"caseId\"."
+  type        = string
+}
+
+variable "unregistered-event-policy" {
+  default     = "skip"
+  description = "What happens to stream events the lambda has no handler for: \"skip\", \"fail\" or \"deadletter\"."
+  type        = string
+}
+
+variable "idempotency-table" {
+  default     = ""
+  description = "The DynamoDB table that remembers processed event IDs, keyed by eventId with expiresAt as its TTL attribute. When empty each execution environment remembers events in memory."
+  type        = string
+}
+
+variable "idempotency-ttl" {
+  default     = "24h"
+  description = "How long a processed event ID is remembered. This should be longer than the stream retention period."
+  type        = string
+}
+
 variable "tenant-cluster-map"{
-  description = "The name for the tenant cluster map we use for lookups."
+  description = "The name for the tenant cluster map we use for lookups, a DynamoDB table keyed by tenantId and businessUnitId holding the clusterId and endpoint of each assignment. A businessUnitId of 0 assigns every business unit of the tenant."
   type = string
 }
 
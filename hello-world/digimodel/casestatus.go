@@ -0,0 +1,93 @@
+package digimodel
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ErrValidation is wrapped by every ValidationError
+var ErrValidation = errors.New("stream event failed validation")
+
+// ValidationError lists the required values that are missing from a StreamEventRequest
+type ValidationError struct {
+	EventID string
+	Fields  []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: event %s is missing %s", ErrValidation, e.EventID, strings.Join(e.Fields, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// DigiCaseStatusUpdate is the case status change that is sent on to the VC for a CaseStatusChanged event
+type DigiCaseStatusUpdate struct {
+	EventID         string
+	TenantID        string
+	BusinessUnitID  int32
+	CaseID          string
+	ContactGUID     string
+	InteractionID   string
+	Status          string
+	RoutingQueueID  string
+	StatusUpdatedAt *timestamppb.Timestamp
+}
+
+// NewDigiCaseStatusUpdate converts a CaseStatusChanged event into a DigiCaseStatusUpdate.
+// The case is read from Data.Case, or from Data.Contact for streams that use the newer contact view.
+// A *ValidationError is returned when any required value is missing.
+func NewDigiCaseStatusUpdate(event *StreamEventRequest) (*DigiCaseStatusUpdate, error) {
+	c := event.Data.Case
+	if c.ID == "" {
+		c = event.Data.Contact
+	}
+
+	update := &DigiCaseStatusUpdate{
+		EventID:         event.EventID,
+		TenantID:        strings.TrimSpace(event.Data.Brand.TenantID),
+		BusinessUnitID:  event.Data.Brand.BusinessUnitID,
+		CaseID:          c.ID,
+		ContactGUID:     c.ContactId,
+		InteractionID:   c.InteractionId,
+		Status:          strings.TrimSpace(c.Status),
+		RoutingQueueID:  c.RoutingQueueId,
+		StatusUpdatedAt: statusUpdatedAt(c),
+	}
+
+	var missing []string
+	if update.TenantID == "" {
+		missing = append(missing, "data.brand.tenantId")
+	}
+	if update.BusinessUnitID == 0 {
+		missing = append(missing, "data.brand.businessUnitId")
+	}
+	if update.CaseID == "" {
+		missing = append(missing, "data.case.id")
+	}
+	if update.ContactGUID == "" {
+		missing = append(missing, "data.case.contactId")
+	}
+	if update.Status == "" {
+		missing = append(missing, "data.case.status")
+	}
+	if update.StatusUpdatedAt == nil {
+		missing = append(missing, "data.case.statusUpdatedAt")
+	}
+	if len(missing) > 0 {
+		return nil, &ValidationError{EventID: event.EventID, Fields: missing}
+	}
+	return update, nil
+}
+
+// statusUpdatedAt prefers the millisecond precision timestamp when DFO sends both
+func statusUpdatedAt(c Case) *timestamppb.Timestamp {
+	if c.StatusUpdatedAtWithMilliseconds != nil {
+		return c.StatusUpdatedAtWithMilliseconds.Timestamp()
+	}
+	return c.StatusUpdatedAt.Timestamp()
+}
@@ -0,0 +1,62 @@
+package digimodel
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNewDigiCaseStatusUpdate(t *testing.T) {
+	t.Run("Case", func(t *testing.T) {
+		var event StreamEventRequest
+		err := json.Unmarshal([]byte(`{"eventId":"e1","eventObject":"Case","eventType":"CaseStatusChanged","data":{
+			"brand":{"tenantId":" 11 ","businessUnitId":4},
+			"case":{"id":"c1","contactId":"g1","interactionId":"i1","status":"closed","routingQueueId":"q1",
+				"statusUpdatedAt":"2024-05-01T10:00:00Z","statusUpdatedAtWithMilliseconds":"2024-05-01T10:00:00.123Z"}}}`), &event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		update, err := NewDigiCaseStatusUpdate(&event)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if update.TenantID != "11" || update.BusinessUnitID != 4 || update.CaseID != "c1" || update.ContactGUID != "g1" ||
+			update.InteractionID != "i1" || update.Status != "closed" || update.RoutingQueueID != "q1" {
+			t.Fatalf("Unexpected update %+v", update)
+		}
+		if update.StatusUpdatedAt.AsTime().Nanosecond() != 123000000 {
+			t.Fatalf("Expected the millisecond timestamp to be used, got %v", update.StatusUpdatedAt.AsTime())
+		}
+	})
+
+	t.Run("Contact", func(t *testing.T) {
+		var event StreamEventRequest
+		err := json.Unmarshal([]byte(`{"eventId":"e2","data":{"brand":{"tenantId":"11","businessUnitId":4},
+			"contact":{"id":"c2","contactId":"g2","status":"open","statusUpdatedAt":"2024-05-01T10:00:00Z"}}}`), &event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		update, err := NewDigiCaseStatusUpdate(&event)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if update.CaseID != "c2" || update.ContactGUID != "g2" {
+			t.Fatalf("Unexpected update %+v", update)
+		}
+	})
+
+	t.Run("Missing fields", func(t *testing.T) {
+		event := StreamEventRequest{EventID: "e3", Data: Data{Brand: Brand{TenantID: "11"}, Case: Case{ID: "c3"}}}
+
+		_, err := NewDigiCaseStatusUpdate(&event)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !errors.Is(err, ErrValidation) {
+			t.Fatalf("Expected a ValidationError, got %v", err)
+		}
+		if len(validationErr.Fields) != 4 {
+			t.Fatalf("Expected 4 missing fields, got %v", validationErr.Fields)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+package digimodel
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Redaction is how a sensitive value is written to logs
+type Redaction int
+
+// Acceptable `Redaction` values
+const (
+	// RedactNone writes the value as is
+	RedactNone Redaction = iota
+	// RedactMask replaces the value with a fixed mask
+	RedactMask
+	// RedactHash replaces the value with a keyed hash, so equal values can still be correlated
+	RedactHash
+)
+
+var redaction_name = map[Redaction]string{
+	RedactNone: "none",
+	RedactMask: "mask",
+	RedactHash: "hash",
+}
+
+var redaction_value = map[string]Redaction{
+	"none": RedactNone,
+	"mask": RedactMask,
+	"hash": RedactHash,
+}
+
+// RedactionFromString converts a string into a Redaction.
+// If r is not a valid Redaction then RedactNone will be returned
+func RedactionFromString(r string) Redaction {
+	return redaction_value[strings.TrimSpace(strings.ToLower(r))]
+}
+
+func (r Redaction) String() string {
+	return redaction_name[r]
+}
+
+// mask replaces every value redacted with RedactMask
+const mask = "***"
+
+// Policy overrides the `pii` struct tags of model fields.
+// Keys name the type and field, such as "User.EmailAddress".
+type Policy map[string]Redaction
+
+// ParsePolicy reads a Policy from a comma separated list such as "User.EmailAddress=mask,Case.DetailUrl=hash"
+func ParsePolicy(s string) (Policy, error) {
+	policy := Policy{}
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(entry, "=")
+		redaction, valid := redaction_value[strings.TrimSpace(strings.ToLower(value))]
+		if !ok || !valid || !strings.Contains(field, ".") {
+			return nil, fmt.Errorf("invalid redaction policy entry %q", entry)
+		}
+		policy[strings.TrimSpace(field)] = redaction
+	}
+	return policy, nil
+}
+
+// Redactor makes model values safe to log.
+// Sensitive fields are marked with a `pii:"mask"` or `pii:"hash"` struct tag, which a Policy may override.
+type Redactor struct {
+	policy Policy
+	key    []byte
+}
+
+// NewRedactor returns a Redactor applying policy on top of the struct tags.
+// Hashes are keyed with key so they cannot be reversed by hashing guesses, an empty key uses a plain hash.
+func NewRedactor(policy Policy, key []byte) *Redactor {
+	return &Redactor{policy: policy, key: key}
+}
+
+// DefaultRedactor is used by LogSafe and RedactJSON
+var DefaultRedactor = NewRedactor(nil, nil)
+
+// LogSafe returns a zap object marshaler for v that redacts sensitive fields with DefaultRedactor.
+// v may be any model type or a pointer to one.
+func LogSafe(v interface{}) zapcore.ObjectMarshaler {
+	return DefaultRedactor.LogObject(v)
+}
+
+// RedactJSON redacts a raw stream event payload with DefaultRedactor, see Redactor.RedactJSON
+func RedactJSON(data []byte) []byte {
+	return DefaultRedactor.RedactJSON(data)
+}
+
+// redaction returns how field of struct type t is redacted
+func (r *Redactor) redaction(t reflect.Type, field reflect.StructField) Redaction {
+	if redaction, ok := r.policy[t.Name()+"."+field.Name]; ok {
+		return redaction
+	}
+	return RedactionFromString(field.Tag.Get("pii"))
+}
+
+// redact applies redaction to value, empty values are left empty
+func (r *Redactor) redact(redaction Redaction, value string) string {
+	if value == "" {
+		return value
+	}
+	switch redaction {
+	case RedactMask:
+		return mask
+	case RedactHash:
+		var sum []byte
+		if len(r.key) > 0 {
+			h := hmac.New(sha256.New, r.key)
+			h.Write([]byte(value))
+			sum = h.Sum(nil)
+		} else {
+			s := sha256.Sum256([]byte(value))
+			sum = s[:]
+		}
+		return "hash:" + hex.EncodeToString(sum[:8])
+	default:
+		return value
+	}
+}
+
+// jsonName returns the JSON key of field, or "" when it is not marshalled
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
+}
+
+var (
+	customTimestampType = reflect.TypeOf(CustomTimestamp{})
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// LogObject returns a zap object marshaler for v that redacts sensitive fields.
+// Fields are keyed by their JSON name and zero values are left out to keep log lines short.
+func (r *Redactor) LogObject(v interface{}) zapcore.ObjectMarshaler {
+	return logObject{r: r, v: reflect.ValueOf(v)}
+}
+
+type logObject struct {
+	r *Redactor
+	v reflect.Value
+}
+
+func (o logObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	v := reflect.Indirect(o.v)
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot log %s as an object", v.Type())
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := jsonName(field)
+		if !field.IsExported() || key == "" || v.Field(i).IsZero() {
+			continue
+		}
+		if err := o.r.encodeField(enc, key, o.r.redaction(t, field), v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Redactor) encodeField(enc zapcore.ObjectEncoder, key string, redaction Redaction, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == customTimestampType:
+		if !v.CanAddr() {
+			addressable := reflect.New(customTimestampType).Elem()
+			addressable.Set(v)
+			v = addressable
+		}
+		ts := v.Addr().Interface().(*CustomTimestamp)
+		enc.AddString(key, ts.Timestamp().AsTime().Format(time.RFC3339Nano))
+		return nil
+	case v.Kind() != reflect.Struct && v.Type().Implements(stringerType):
+		enc.AddString(key, v.Interface().(fmt.Stringer).String())
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		enc.AddString(key, r.redact(redaction, v.String()))
+	case reflect.Bool:
+		enc.AddBool(key, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		enc.AddInt64(key, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		enc.AddUint64(key, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		enc.AddFloat64(key, v.Float())
+	case reflect.Struct:
+		return enc.AddObject(key, logObject{r: r, v: v})
+	case reflect.Slice, reflect.Array:
+		return enc.AddArray(key, logArray{r: r, redaction: redaction, v: v})
+	default:
+		enc.AddString(key, mask)
+	}
+	return nil
+}
+
+type logArray struct {
+	r         *Redactor
+	redaction Redaction
+	v         reflect.Value
+}
+
+func (a logArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for i := 0; i < a.v.Len(); i++ {
+		item := reflect.Indirect(a.v.Index(i))
+		switch item.Kind() {
+		case reflect.Struct:
+			if err := enc.AppendObject(logObject{r: a.r, v: item}); err != nil {
+				return err
+			}
+		case reflect.String:
+			enc.AppendString(a.r.redact(a.redaction, item.String()))
+		default:
+			enc.AppendString(a.r.redact(a.redaction, fmt.Sprint(item.Interface())))
+		}
+	}
+	return nil
+}
+
+var streamEventRequestType = reflect.TypeOf(StreamEventRequest{})
+
+// RedactJSON returns a copy of a raw stream event payload with sensitive values redacted.
+// The payload is walked along the StreamEventRequest model, and string values under keys the model does not
+// know are masked because nothing says they are safe. A payload that is not JSON is replaced by a note of its size.
+func (r *Redactor) RedactJSON(data []byte) []byte {
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return []byte(fmt.Sprintf(`"%d bytes of invalid JSON"`, len(data)))
+	}
+	redacted, err := json.Marshal(r.redactJSONValue(payload, streamEventRequestType, RedactNone))
+	if err != nil {
+		return []byte(fmt.Sprintf(`"%d bytes of unredactable JSON"`, len(data)))
+	}
+	return redacted
+}
+
+// redactJSONValue redacts value, which was decoded from JSON, as the model type t.
+// A nil t means the model does not know the value.
+func (r *Redactor) redactJSONValue(value interface{}, t reflect.Type, redaction Redaction) interface{} {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			fieldType, fieldRedaction := r.jsonField(t, key)
+			v[key] = r.redactJSONValue(item, fieldType, fieldRedaction)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactJSONValue(item, t, redaction)
+		}
+		return v
+	case string:
+		if t == nil {
+			return r.redact(RedactMask, v)
+		}
+		return r.redact(redaction, v)
+	default:
+		return v
+	}
+}
+
+// jsonField finds the field of struct type t marshalled under key, matching case insensitively like encoding/json
+func (r *Redactor) jsonField(t reflect.Type, key string) (reflect.Type, Redaction) {
+	if t == nil || t.Kind() != reflect.Struct || t == customTimestampType {
+		return nil, RedactNone
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && strings.EqualFold(jsonName(field), key) {
+			return field.Type, r.redaction(t, field)
+		}
+	}
+	return nil, RedactNone
+}
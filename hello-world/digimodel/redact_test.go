@@ -0,0 +1,132 @@
+package digimodel
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const sensitiveEvent = `{"eventId":"e1","eventObject":"Message","eventType":"MessageCreated","data":{
+	"brand":{"tenantId":"11","businessUnitId":4},
+	"channel":{"id":"ch1","name":"Support"},
+	"user":{"id":7,"emailAddress":"agent@example.com","firstName":"Ada","nickname":"ada"},
+	"message":{"ID":"m1","messageContent":{"text":"my card is 4111","type":"TEXT"},
+		"authorEndUserIdentity":{"fullName":"Grace Hopper","image":"https://example.com/g.png"},"createdAt":"2024-05-01T10:00:00Z",
+		"tags":[{"id":1,"title":"vip"}]},
+	"case":{"id":"c1","endUserRecipients":[{"idOnExternalPlatform":"+15550100","name":"Grace"}]},
+	"extra":{"note":"call me at 555-0100"}}}`
+
+func TestLogSafe(t *testing.T) {
+	var event StreamEventRequest
+	if err := json.Unmarshal([]byte(sensitiveEvent), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	if err := enc.AddObject("event", LogSafe(&event)); err != nil {
+		t.Fatal(err)
+	}
+	logged, _ := json.Marshal(enc.Fields)
+	line := string(logged)
+
+	for _, secret := range []string{"agent@example.com", "Ada", "4111", "Grace", "g.png", "+15550100"} {
+		if strings.Contains(line, secret) {
+			t.Fatalf("Expected %q to be redacted, got %s", secret, line)
+		}
+	}
+	for _, kept := range []string{`"eventId":"e1"`, `"eventType":"MessageCreated"`, `"name":"Support"`, `"createdAt":"2024-05-01T10:00:00Z"`, `"title":"vip"`} {
+		if !strings.Contains(line, kept) {
+			t.Fatalf("Expected %s to be logged, got %s", kept, line)
+		}
+	}
+	if !strings.Contains(line, `"emailAddress":"hash:`) || !strings.Contains(line, `"text":"***"`) {
+		t.Fatalf("Expected the email to be hashed and the text masked, got %s", line)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	user := User{EmailAddress: "agent@example.com", FirstName: "Ada", InContactID: "ic-1"}
+
+	t.Run("Hashes are stable and keyed", func(t *testing.T) {
+		plain := NewRedactor(nil, nil).redact(RedactHash, user.EmailAddress)
+		if plain != NewRedactor(nil, nil).redact(RedactHash, user.EmailAddress) {
+			t.Fatal("Expected equal values to hash equally")
+		}
+		if keyed := NewRedactor(nil, []byte("secret")).redact(RedactHash, user.EmailAddress); keyed == plain {
+			t.Fatal("Expected the key to change the hash")
+		}
+	})
+
+	t.Run("Policy overrides struct tags", func(t *testing.T) {
+		policy, err := ParsePolicy("User.EmailAddress=mask, User.InContactID=hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc := zapcore.NewMapObjectEncoder()
+		NewRedactor(policy, nil).LogObject(user).MarshalLogObject(enc)
+		if enc.Fields["emailAddress"] != mask || !strings.HasPrefix(enc.Fields["incontactId"].(string), "hash:") || enc.Fields["firstName"] != mask {
+			t.Fatalf("Unexpected fields %v", enc.Fields)
+		}
+	})
+
+	t.Run("Invalid policy", func(t *testing.T) {
+		for _, policy := range []string{"EmailAddress=mask", "User.EmailAddress=scramble", "User.EmailAddress"} {
+			if _, err := ParsePolicy(policy); err == nil {
+				t.Fatalf("Expected an error for %q", policy)
+			}
+		}
+	})
+}
+
+func TestRedactJSON(t *testing.T) {
+	redacted := string(RedactJSON([]byte(sensitiveEvent)))
+	for _, secret := range []string{"agent@example.com", "Ada", "4111", "Grace", "g.png", "+15550100", "555-0100"} {
+		if strings.Contains(redacted, secret) {
+			t.Fatalf("Expected %q to be redacted, got %s", secret, redacted)
+		}
+	}
+	for _, kept := range []string{`"eventId":"e1"`, `"name":"Support"`, `"createdAt":"2024-05-01T10:00:00Z"`, `"tenantId":"11"`} {
+		if !strings.Contains(redacted, kept) {
+			t.Fatalf("Expected %s to be kept, got %s", kept, redacted)
+		}
+	}
+
+	if got := string(RedactJSON([]byte("agent@example.com"))); got != `"17 bytes of invalid JSON"` {
+		t.Fatalf("Expected invalid JSON to be replaced, got %s", got)
+	}
+}
+
+// TestPersonalFieldsAreTagged guards against new model fields that carry personal data without a pii tag
+func TestPersonalFieldsAreTagged(t *testing.T) {
+	personal := []string{"name", "email", "image", "nick", "username", "text", "postback", "number"}
+	// Names of channels, queues and tags describe configuration rather than people
+	allowed := map[string]bool{"Channel.Name": true, "RoutingQueue.Name": true, "SubQueue.Name": true, "Changes.FieldName": true}
+
+	seen := map[reflect.Type]bool{}
+	var check func(reflect.Type)
+	check = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || typ == customTimestampType || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name := typ.Name() + "." + field.Name
+			if field.Type.Kind() == reflect.String && field.Tag.Get("pii") == "" && !allowed[name] {
+				for _, word := range personal {
+					if strings.Contains(strings.ToLower(field.Name), word) {
+						t.Errorf("Expected %s to have a pii tag", name)
+					}
+				}
+			}
+			check(field.Type)
+		}
+	}
+	check(streamEventRequestType)
+}
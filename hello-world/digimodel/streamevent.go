@@ -426,18 +426,18 @@ type Data struct {
 
 type EndUser struct {
 	ID        string `json:"id"`
-	FirstName string `json:"firstName"`
-	Surname   string `json:"surname"`
+	FirstName string `json:"firstName" pii:"mask"`
+	Surname   string `json:"surname" pii:"mask"`
 }
 
 type EndUserIdentity struct {
-	FirstName            string `json:"firstName"`
-	FullName             string `json:"fullName"`
+	FirstName            string `json:"firstName" pii:"mask"`
+	FullName             string `json:"fullName" pii:"mask"`
 	ID                   string `json:"ID"`
-	IdOnExternalPlatform string `json:"idOnExternalPlatform"`
-	Image                string `json:"image"`
-	LastName             string `json:"lastName"`
-	NickName             string `json:"nickname"`
+	IdOnExternalPlatform string `json:"idOnExternalPlatform" pii:"hash"`
+	Image                string `json:"image" pii:"mask"`
+	LastName             string `json:"lastName" pii:"mask"`
+	NickName             string `json:"nickname" pii:"mask"`
 }
 
 type Interaction struct {
@@ -452,7 +452,7 @@ type Message struct {
 	AuthorNameRemoved          ContentRemoved     `json:"authorNameRemoved"`
 	AuthorUser                 User               `json:"authorUser"`
 	ContentRemoved             ContentRemoved     `json:"contentRemoved"`
-	ContactNumber              string             `json:"contactNumber,omitempty"`
+	ContactNumber              string             `json:"contactNumber,omitempty" pii:"hash"`
 	CreatedAt                  *CustomTimestamp   `json:"createdAt"`
 	DeletedOnExternalPlatform  bool               `json:"deletedOnExternalPlatform"`
 	Direction                  string             `json:"direction"`
@@ -469,14 +469,14 @@ type Message struct {
 }
 
 type MessageContent struct {
-	Text    string  `json:"text"`
+	Text    string  `json:"text" pii:"mask"`
 	Type    string  `json:"type"`
 	Payload Payload `json:"payload"`
 }
 
 type Payload struct {
-	Text     string `json:"text"`
-	Postback string `json:"postback"`
+	Text     string `json:"text" pii:"mask"`
+	Postback string `json:"postback" pii:"mask"`
 }
 
 type ReactionStatistics struct {
@@ -487,17 +487,17 @@ type ReactionStatistics struct {
 }
 
 type Recipient struct {
-	IdOnExternalPlatform string `json:"idOnExternalPlatform"`
-	Name                 string `json:"name"`
+	IdOnExternalPlatform string `json:"idOnExternalPlatform" pii:"hash"`
+	Name                 string `json:"name" pii:"mask"`
 	IsPrimary            bool   `json:"isPrimary"`
 	IsPrivate            bool   `json:"isPrivate"`
 }
 
 type RecipientCustomer struct {
 	Id        string `json:"id"`
-	FirstName string `json:"firstName"`
-	Surname   string `json:"surname"`
-	FullName  string `json:"fullName"`
+	FirstName string `json:"firstName" pii:"mask"`
+	Surname   string `json:"surname" pii:"mask"`
+	FullName  string `json:"fullName" pii:"mask"`
 }
 
 type ReplyToMessage struct {
@@ -546,19 +546,19 @@ type Tag struct {
 type Thread struct {
 	ID                   string `json:"id,omitempty"`
 	IdOnExternalPlatform string `json:"idOnExternalPlatform,omitempty"`
-	ThreadName           string `json:"threadName,omitempty"`
+	ThreadName           string `json:"threadName,omitempty" pii:"mask"`
 }
 
 type User struct {
 	ID            int64  `json:"id"`
 	InContactID   string `json:"incontactId"`
 	IsBotUser     bool   `json:"isBotUser"`
-	EmailAddress  string `json:"emailAddress"`
-	LoginUsername string `json:"loginUsername"`
-	FirstName     string `json:"firstName"`
-	SurName       string `json:"surname"`
-	NickName      string `json:"nickname"`
-	ImageUrl      string `json:"imageUrl"`
+	EmailAddress  string `json:"emailAddress" pii:"hash"`
+	LoginUsername string `json:"loginUsername" pii:"hash"`
+	FirstName     string `json:"firstName" pii:"mask"`
+	SurName       string `json:"surname" pii:"mask"`
+	NickName      string `json:"nickname" pii:"mask"`
+	ImageUrl      string `json:"imageUrl" pii:"mask"`
 	IsSurveyUser  bool   `json:"isSurveyUser"`
 }
 
@@ -0,0 +1,165 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"hello-world/batch"
+	"hello-world/digimodel"
+)
+
+// Wildcards match every EventObject or EventType when registering a Handler
+const (
+	AnyEventObject digimodel.EventObject = -1
+	AnyEventType   digimodel.EventType   = -1
+)
+
+// ErrUnregistered is returned by Dispatch for events without a Handler when the Policy is PolicyFail or PolicyDeadLetter
+var ErrUnregistered = errors.New("no handler registered for event")
+
+// Handler processes a single decoded stream event
+type Handler func(ctx context.Context, event *digimodel.StreamEventRequest) error
+
+// Skip is a Handler that ignores the event.
+// Registering it makes ignoring an event type explicit instead of relying on the Policy.
+func Skip(context.Context, *digimodel.StreamEventRequest) error {
+	return nil
+}
+
+// Policy decides what happens to an event that has no registered Handler
+type Policy int
+
+// Acceptable `Policy` values
+const (
+	// PolicySkip treats the event as successfully processed
+	PolicySkip Policy = iota
+	// PolicyFail reports the record as a retryable failure
+	PolicyFail
+	// PolicyDeadLetter reports the record as a permanent failure so it is dead-lettered
+	PolicyDeadLetter
+)
+
+var policy_name = map[Policy]string{
+	PolicySkip:       "skip",
+	PolicyFail:       "fail",
+	PolicyDeadLetter: "deadletter",
+}
+
+var policy_value = map[string]Policy{
+	"skip":       PolicySkip,
+	"fail":       PolicyFail,
+	"deadletter": PolicyDeadLetter,
+}
+
+// PolicyFromString converts a string into a Policy.
+// If p is not a valid Policy then PolicySkip will be returned
+func PolicyFromString(p string) Policy {
+	return policy_value[strings.TrimSpace(strings.ToLower(p))]
+}
+
+func (p Policy) String() string {
+	return policy_name[p]
+}
+
+// Outcome describes what Dispatch does with an event
+type Outcome int
+
+// Acceptable `Outcome` values
+const (
+	// OutcomeHandled means a registered Handler processes the event
+	OutcomeHandled Outcome = iota
+	// OutcomeSkipped means the event is ignored under PolicySkip
+	OutcomeSkipped
+	// OutcomeFailed means the record is retried under PolicyFail
+	OutcomeFailed
+	// OutcomeDeadLettered means the record is dead-lettered under PolicyDeadLetter
+	OutcomeDeadLettered
+)
+
+var outcome_name = map[Outcome]string{
+	OutcomeHandled:      "Handled",
+	OutcomeSkipped:      "Skipped",
+	OutcomeFailed:       "Failed",
+	OutcomeDeadLettered: "DeadLettered",
+}
+
+func (o Outcome) String() string {
+	return outcome_name[o]
+}
+
+type route struct {
+	object    digimodel.EventObject
+	eventType digimodel.EventType
+}
+
+// Registry routes stream events to the Handler registered for their EventObject and EventType.
+// Registration is not safe for concurrent use and should finish before the first Dispatch.
+type Registry struct {
+	handlers     map[route]Handler
+	unregistered Policy
+}
+
+// NewRegistry returns an empty Registry that applies policy to events without a Handler
+func NewRegistry(policy Policy) *Registry {
+	return &Registry{
+		handlers:     map[route]Handler{},
+		unregistered: policy,
+	}
+}
+
+// Register routes events matching object and eventType to handler.
+// Either value may be a wildcard, and registering the same pair twice replaces the earlier Handler.
+func (r *Registry) Register(object digimodel.EventObject, eventType digimodel.EventType, handler Handler) {
+	r.handlers[route{object: object, eventType: eventType}] = handler
+}
+
+// Lookup returns the Handler for object and eventType.
+// Exact registrations win over a wildcard EventObject, which wins over a wildcard EventType,
+// which wins over a registration where both are wildcards.
+func (r *Registry) Lookup(object digimodel.EventObject, eventType digimodel.EventType) (Handler, bool) {
+	for _, candidate := range []route{
+		{object: object, eventType: eventType},
+		{object: AnyEventObject, eventType: eventType},
+		{object: object, eventType: AnyEventType},
+		{object: AnyEventObject, eventType: AnyEventType},
+	} {
+		if handler, ok := r.handlers[candidate]; ok {
+			return handler, true
+		}
+	}
+	return nil, false
+}
+
+// Outcome returns what Dispatch does with events matching object and eventType
+func (r *Registry) Outcome(object digimodel.EventObject, eventType digimodel.EventType) Outcome {
+	if _, ok := r.Lookup(object, eventType); ok {
+		return OutcomeHandled
+	}
+	switch r.unregistered {
+	case PolicyFail:
+		return OutcomeFailed
+	case PolicyDeadLetter:
+		return OutcomeDeadLettered
+	default:
+		return OutcomeSkipped
+	}
+}
+
+// Dispatch hands event to its Handler, or applies the Policy when there is none
+func (r *Registry) Dispatch(ctx context.Context, event *digimodel.StreamEventRequest) error {
+	if handler, ok := r.Lookup(event.EventObject, event.EventType); ok {
+		return handler(ctx, event)
+	}
+
+	err := fmt.Errorf("%w: EventObject %s EventType %s", ErrUnregistered, event.EventObject, event.EventType)
+	switch r.unregistered {
+	case PolicyFail:
+		return err
+	case PolicyDeadLetter:
+		return batch.Permanent(err)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"hello-world/batch"
+	"hello-world/digimodel"
+)
+
+func named(name string, calls *[]string) Handler {
+	return func(context.Context, *digimodel.StreamEventRequest) error {
+		*calls = append(*calls, name)
+		return nil
+	}
+}
+
+func TestLookupPrecedence(t *testing.T) {
+	var calls []string
+	registry := NewRegistry(PolicySkip)
+	registry.Register(AnyEventObject, AnyEventType, named("any", &calls))
+	registry.Register(digimodel.EventObject_Case, AnyEventType, named("case", &calls))
+	registry.Register(AnyEventObject, digimodel.EventType_CaseStatusChanged, named("status", &calls))
+	registry.Register(digimodel.EventObject_Case, digimodel.EventType_CaseCreated, named("created", &calls))
+
+	for _, event := range []digimodel.StreamEventRequest{
+		{EventObject: digimodel.EventObject_Case, EventType: digimodel.EventType_CaseCreated},
+		{EventObject: digimodel.EventObject_Case, EventType: digimodel.EventType_CaseStatusChanged},
+		{EventObject: digimodel.EventObject_Case, EventType: digimodel.EventType_CaseAgentEnded},
+		{EventObject: digimodel.EventObject_Message, EventType: digimodel.EventType_MessageCreated},
+	} {
+		if err := registry.Dispatch(context.Background(), &event); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	expected := []string{"created", "status", "case", "any"}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("Expected calls %v, got %v", expected, calls)
+		}
+	}
+}
+
+func TestUnregisteredPolicy(t *testing.T) {
+	event := &digimodel.StreamEventRequest{EventObject: digimodel.EventObject_Thread, EventType: digimodel.EventType_ThreadFocused}
+
+	if err := NewRegistry(PolicySkip).Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("PolicySkip should not return an error, got %v", err)
+	}
+
+	err := NewRegistry(PolicyFail).Dispatch(context.Background(), event)
+	if !errors.Is(err, ErrUnregistered) || batch.IsPermanent(err) {
+		t.Fatalf("PolicyFail should return a retryable ErrUnregistered, got %v", err)
+	}
+
+	err = NewRegistry(PolicyDeadLetter).Dispatch(context.Background(), event)
+	if !errors.Is(err, ErrUnregistered) || !batch.IsPermanent(err) {
+		t.Fatalf("PolicyDeadLetter should return a permanent ErrUnregistered, got %v", err)
+	}
+}
+
+func TestEveryEventTypeHasAnOutcome(t *testing.T) {
+	for _, policy := range []Policy{PolicySkip, PolicyFail, PolicyDeadLetter} {
+		registry := NewRegistry(policy)
+		registry.Register(AnyEventObject, digimodel.EventType_CaseStatusChanged, Skip)
+
+		for o := 0; o < digimodel.NumEventObjects(); o++ {
+			for e := 0; e < digimodel.NumEventTypes(); e++ {
+				outcome := registry.Outcome(digimodel.EventObject(o), digimodel.EventType(e))
+				if outcome.String() == "" {
+					t.Fatalf("EventObject %d EventType %d has no outcome", o, e)
+				}
+				if digimodel.EventType(e) == digimodel.EventType_CaseStatusChanged && outcome != OutcomeHandled {
+					t.Fatalf("CaseStatusChanged should be handled, got %s", outcome)
+				}
+			}
+		}
+	}
+}
+
+func TestPolicyFromString(t *testing.T) {
+	if PolicyFromString(" DeadLetter ") != PolicyDeadLetter || PolicyFromString("fail") != PolicyFail || PolicyFromString("bogus") != PolicySkip {
+		t.Fatal("Unexpected policy conversion")
+	}
+}
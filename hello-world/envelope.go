@@ -0,0 +1,38 @@
+package main
+
+import (
+	"hello-world/compression"
+	"hello-world/source"
+)
+
+// unpackRecords decompresses every Kinesis record and replaces the ones carrying a multi-event envelope by their events.
+// A record that fails to decompress keeps its data and the error, so that processRecord fails and dead-letters it.
+// Records of other sources are neither decompressed nor split, their payload is processed as it was delivered.
+func unpackRecords(records []source.Record) []source.Record {
+	unpacked := make([]source.Record, 0, len(records))
+	for _, record := range records {
+		if record.EventSource != source.EventSourceKinesis {
+			unpacked = append(unpacked, record)
+			continue
+		}
+
+		data, format, err := compression.Decompress(record.Data, maxDecompressedSize)
+		record.Compression = format
+		if err != nil {
+			record.DecompressErr = err
+			unpacked = append(unpacked, record)
+			continue
+		}
+		if format != compression.None {
+			record.CompressedSize, record.DecompressedSize = len(record.Data), len(data)
+			record.Data = data
+		}
+
+		if events, ok := source.Split(record); ok {
+			unpacked = append(unpacked, events...)
+			continue
+		}
+		unpacked = append(unpacked, record)
+	}
+	return unpacked
+}
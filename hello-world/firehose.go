@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+	"hello-world/compression"
+	"hello-world/digimodel"
+	"hello-world/lag"
+	"hello-world/metrics"
+)
+
+// firehoseHandler is the Lambda handler function for Kinesis Data Firehose data transformation.
+// Every record is transformed into the normalized and redacted JSON of its StreamEventRequest followed by a newline,
+// so the delivered objects are newline-delimited JSON. Events stopped by RecordRules are dropped and records that
+// cannot be decoded or fail validation are reported as ProcessingFailed, which Firehose delivers to its error output.
+func firehoseHandler(ctx context.Context, firehoseEvent events.KinesisFirehoseEvent) (events.KinesisFirehoseResponse, error) {
+	log := invocationLogger(ctx)
+	ctx = withLogger(ctx, log)
+	ctx, span := startInvocationSpan(ctx, len(firehoseEvent.Records))
+	defer flushTraces(ctx)
+	defer span.End()
+
+	recorder := metrics.NewRecorder(metricNamespace, metricDimensionSets)
+	summaries := make([]recordSummary, len(firehoseEvent.Records))
+	response := events.KinesisFirehoseResponse{Records: make([]events.KinesisFirehoseResponseRecord, len(firehoseEvent.Records))}
+
+	for i, record := range firehoseEvent.Records {
+		ctx := withLogger(ctx, log.With(zap.String("recordId", record.RecordID)))
+		summary := &summaries[i]
+		summary.started = true
+		start := time.Now()
+
+		summary.streamLag = lag.Between(record.ApproximateArrivalTimestamp.Time, start)
+		data, result, err := transformRecord(ctx, record.Data, summary)
+		response.Records[i] = events.KinesisFirehoseResponseRecord{RecordID: record.RecordID, Result: result, Data: data}
+		switch result {
+		case events.KinesisFirehoseTransformedStateProcessingFailed:
+			loggerFrom(ctx).Warn("Failed to transform record", zap.Error(err))
+			summary.record(recorder, string(deadLetterReason(err)), err)
+			// Firehose delivers the original record to its error output
+			response.Records[i].Data = record.Data
+		default:
+			summary.record(recorder, "", nil)
+		}
+		recorder.Duration(metrics.RecordLatency, summary.dimensions(""), time.Since(start))
+	}
+
+	emitMetrics(ctx, recorder, summaries)
+	return response, nil
+}
+
+// transformRecord decodes, filters, validates and redacts a single Firehose record and returns its output data and result
+func transformRecord(ctx context.Context, data []byte, summary *recordSummary) ([]byte, string, error) {
+	data, _, err := compression.Decompress(data, maxDecompressedSize)
+	if errors.Is(err, compression.ErrTooLarge) {
+		return nil, events.KinesisFirehoseTransformedStateProcessingFailed, err
+	}
+	if err != nil {
+		return nil, events.KinesisFirehoseTransformedStateProcessingFailed, fmt.Errorf("%w: %w", errDecodeFailure, err)
+	}
+
+	var event digimodel.StreamEventRequest
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, events.KinesisFirehoseTransformedStateProcessingFailed, fmt.Errorf("%w: %w", errDecodeFailure, err)
+	}
+	summary.eventObject = event.EventObject.String()
+	summary.eventType = event.EventType.String()
+	ctx = withLogger(ctx, loggerFrom(ctx).With(zap.Inline(event)))
+
+	// The analytics bucket only receives the events the stream consumer would act on.
+	// Like the stream consumer, rules run before validation, so a rejected event is dropped even when invalid.
+	if stop, _ := applyRules(ctx, &event); stop {
+		summary.skipped = true
+		return nil, events.KinesisFirehoseTransformedStateDropped, nil
+	}
+
+	if err := validateEvent(&event); err != nil {
+		return nil, events.KinesisFirehoseTransformedStateProcessingFailed, err
+	}
+
+	normalized, err := json.Marshal(&event)
+	if err != nil {
+		return nil, events.KinesisFirehoseTransformedStateProcessingFailed, err
+	}
+	return append(digimodel.RedactJSON(normalized), '\n'), events.KinesisFirehoseTransformedStateOk, nil
+}
+
+// validateEvent checks that event has the values its handler requires, events without a handler are not validated
+func validateEvent(event *digimodel.StreamEventRequest) error {
+	if event.EventType != digimodel.EventType_CaseStatusChanged {
+		return nil
+	}
+	_, err := digimodel.NewDigiCaseStatusUpdate(event)
+	return err
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"hello-world/batch"
+	"hello-world/digimodel"
+	"hello-world/dispatch"
+)
+
+// eventDispatcher routes decoded stream events to their handler.
+// Events without a handler follow the UnregisteredEventPolicy environment variable, which defaults to skip.
+var eventDispatcher = newEventDispatcher(dispatch.PolicySkip)
+
+// newEventDispatcher registers every stream event this lambda acts on
+func newEventDispatcher(unregistered dispatch.Policy) *dispatch.Registry {
+	registry := dispatch.NewRegistry(unregistered)
+	// DFO sends status changes with an EventObject of Case on older streams and Contact on newer ones
+	registry.Register(dispatch.AnyEventObject, digimodel.EventType_CaseStatusChanged, handleCaseStatusChanged)
+	return registry
+}
+
+func handleCaseStatusChanged(ctx context.Context, event *digimodel.StreamEventRequest) error {
+	// An event missing required values will never become valid, so it is dead-lettered
+	update, err := digimodel.NewDigiCaseStatusUpdate(event)
+	if err != nil {
+		return batch.Permanent(err)
+	}
+	loggerFrom(ctx).Debug("Built case status update", zap.String("caseId", update.CaseID), zap.String("status", update.Status))
+
+	// Locate ClusterServerInfo. A tenant without a cluster may still be provisioning,
+	// so it is retried until MaxRecordAttempts dead-letters it.
+	info, err := clusterResolver.Resolve(ctx, update.TenantID, update.BusinessUnitID)
+	if err != nil {
+		return err
+	}
+
+	// GetOrCreateClusterPersisterForTarget and UpdatePersisterTargetStatus to store most recent error
+	return clusterPersisters.Send(ctx, info, update)
+}
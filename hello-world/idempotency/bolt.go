@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var completedBucket = []byte("completed")
+
+// BoltStore keeps completed events in an embedded key value file.
+// Like MemoryStore it is local to one execution environment, but it survives restarts of a local run.
+type BoltStore struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+// NewBoltStore opens or creates the store file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open idempotency file %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(completedBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create idempotency bucket: %w", err)
+	}
+	return &BoltStore{db: db, now: time.Now}, nil
+}
+
+// Completed implements Store
+func (s *BoltStore) Completed(_ context.Context, eventID string) (bool, error) {
+	var completed bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(completedBucket).Get([]byte(eventID))
+		if len(value) == 8 {
+			expiresAt := time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+			completed = s.now().Before(expiresAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to read idempotency file: %w", err)
+	}
+	return completed, nil
+}
+
+// MarkCompleted implements Store
+func (s *BoltStore) MarkCompleted(_ context.Context, eventID string, ttl time.Duration) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(s.now().Add(ttl).UnixNano()))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(completedBucket).Put([]byte(eventID), value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write idempotency file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the store file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
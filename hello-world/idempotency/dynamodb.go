@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attribute names of the DynamoDB idempotency table.
+// The table must have eventId as its partition key, and expiresAt should be enabled as its TTL attribute.
+const (
+	attributeEventID   = "eventId"
+	attributeExpiresAt = "expiresAt"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB client used by DynamoDBStore
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoDBStore keeps completed events in a DynamoDB table, which is shared by every execution environment
+type DynamoDBStore struct {
+	client DynamoDBAPI
+	table  string
+	now    func() time.Time
+}
+
+// NewDynamoDBStore returns a DynamoDBStore that uses table through client
+func NewDynamoDBStore(client DynamoDBAPI, table string) *DynamoDBStore {
+	return &DynamoDBStore{client: client, table: table, now: time.Now}
+}
+
+// NewDynamoDBClient loads the default AWS configuration and returns a DynamoDB client.
+// If endpoint is not empty every request is sent there instead, which allows DynamoDB Local to be used.
+func NewDynamoDBClient(ctx context.Context, endpoint string) (*dynamodb.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+// Completed implements Store.
+// DynamoDB deletes expired items lazily, so the expiry is checked here as well.
+func (s *DynamoDBStore) Completed(ctx context.Context, eventID string) (bool, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]types.AttributeValue{attributeEventID: &types.AttributeValueMemberS{Value: eventID}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get event %s from %s: %w", eventID, s.table, err)
+	}
+
+	expiresAt, ok := output.Item[attributeExpiresAt].(*types.AttributeValueMemberN)
+	if !ok {
+		return false, nil
+	}
+	seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s for event %s: %w", attributeExpiresAt, eventID, err)
+	}
+	return s.now().Unix() < seconds, nil
+}
+
+// MarkCompleted implements Store
+func (s *DynamoDBStore) MarkCompleted(ctx context.Context, eventID string, ttl time.Duration) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]types.AttributeValue{
+			attributeEventID:   &types.AttributeValueMemberS{Value: eventID},
+			attributeExpiresAt: &types.AttributeValueMemberN{Value: strconv.FormatInt(s.now().Add(ttl).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put event %s into %s: %w", eventID, s.table, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a completed event is remembered when no TTL is configured.
+// It should outlive the retention of the stream so that any redelivery is recognised.
+const DefaultTTL = 24 * time.Hour
+
+// Store remembers which stream events were processed to completion.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Completed reports whether eventID was marked completed and has not expired
+	Completed(ctx context.Context, eventID string) (bool, error)
+	// MarkCompleted remembers eventID as completed until ttl has elapsed
+	MarkCompleted(ctx context.Context, eventID string, ttl time.Duration) error
+}
+
+// maxMemoryEntries bounds the size of a MemoryStore, the oldest entry is evicted to make room for a new one
+const maxMemoryEntries = 10000
+
+// MemoryStore keeps completed events in memory.
+// It only deduplicates within one warm execution environment, so it suits tests and local runs.
+type MemoryStore struct {
+	mu sync.Mutex
+	// entries holds a *memoryEntry per event, oldest first, and byID indexes it
+	entries *list.List
+	byID    map[string]*list.Element
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	eventID   string
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: list.New(),
+		byID:    map[string]*list.Element{},
+		now:     time.Now,
+	}
+}
+
+// Completed implements Store
+func (s *MemoryStore) Completed(_ context.Context, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.byID[eventID]
+	return ok && s.now().Before(element.Value.(*memoryEntry).expiresAt), nil
+}
+
+// MarkCompleted implements Store
+func (s *MemoryStore) MarkCompleted(_ context.Context, eventID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt := s.now().Add(ttl)
+	if element, ok := s.byID[eventID]; ok {
+		element.Value.(*memoryEntry).expiresAt = expiresAt
+		s.entries.MoveToBack(element)
+		return nil
+	}
+	for s.entries.Len() >= maxMemoryEntries {
+		oldest := s.entries.Remove(s.entries.Front()).(*memoryEntry)
+		delete(s.byID, oldest.eventID)
+	}
+	s.byID[eventID] = s.entries.PushBack(&memoryEntry{eventID: eventID, expiresAt: expiresAt})
+	return nil
+}
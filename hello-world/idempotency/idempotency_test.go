@@ -0,0 +1,114 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+// testStore checks the Store contract, moving c forward to expire entries
+func testStore(t *testing.T, store Store, c *clock) {
+	ctx := context.Background()
+
+	completed, err := store.Completed(ctx, "event-1")
+	if err != nil || completed {
+		t.Fatalf("Expected event-1 to not be completed, got %v %v", completed, err)
+	}
+
+	if err := store.MarkCompleted(ctx, "event-1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	completed, err = store.Completed(ctx, "event-1")
+	if err != nil || !completed {
+		t.Fatalf("Expected event-1 to be completed, got %v %v", completed, err)
+	}
+
+	c.now = c.now.Add(2 * time.Hour)
+	completed, err = store.Completed(ctx, "event-1")
+	if err != nil || completed {
+		t.Fatalf("Expected event-1 to have expired, got %v %v", completed, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	c := &clock{now: time.Now()}
+	store := NewMemoryStore()
+	store.now = c.Now
+	testStore(t, store, c)
+}
+
+func TestMemoryStoreEvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for i := 0; i < maxMemoryEntries+10; i++ {
+		if err := store.MarkCompleted(ctx, fmt.Sprintf("event-%d", i), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.byID) != maxMemoryEntries || store.entries.Len() != maxMemoryEntries {
+		t.Fatalf("Expected %d entries, got %d", maxMemoryEntries, len(store.byID))
+	}
+	for _, test := range []struct {
+		eventID   string
+		completed bool
+	}{
+		{"event-0", false},
+		{"event-9", false},
+		{"event-10", true},
+		{fmt.Sprintf("event-%d", maxMemoryEntries+9), true},
+	} {
+		completed, err := store.Completed(ctx, test.eventID)
+		if err != nil || completed != test.completed {
+			t.Fatalf("Expected %s completed to be %v, got %v %v", test.eventID, test.completed, completed, err)
+		}
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	c := &clock{now: time.Now()}
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "idempotency.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.now = c.Now
+	testStore(t, store, c)
+}
+
+// fakeDynamoDB is an in-memory stand-in for a DynamoDB table keyed by eventId
+type fakeDynamoDB struct {
+	items map[string]map[string]types.AttributeValue
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	key := params.Key[attributeEventID].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f.items[aws.ToString(params.TableName)+"/"+key]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	key := params.Item[attributeEventID].(*types.AttributeValueMemberS).Value
+	f.items[aws.ToString(params.TableName)+"/"+key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	c := &clock{now: time.Now()}
+	store := NewDynamoDBStore(&fakeDynamoDB{items: map[string]map[string]types.AttributeValue{}}, "idempotency")
+	store.now = c.Now
+	testStore(t, store, c)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"hello-world/batch"
+	"hello-world/digimodel"
+	"hello-world/lag"
+	"hello-world/metrics"
+	"hello-world/source"
+)
+
+var (
+	// staleThreshold is the age after which an event is stale, zero disables the check
+	staleThreshold time.Duration
+
+	// stalePolicy decides what happens to stale events
+	stalePolicy = lag.PolicyProcess
+)
+
+// eventCreatedAt returns when DFO created event, preferring the millisecond precision timestamp
+func eventCreatedAt(event *digimodel.StreamEventRequest) time.Time {
+	if ts := event.CreatedAtWithMilliseconds.Timestamp(); ts != nil {
+		return ts.AsTime()
+	}
+	if ts := event.CreatedAt.Timestamp(); ts != nil {
+		return ts.AsTime()
+	}
+	return time.Time{}
+}
+
+// checkStale applies stalePolicy to an event older than staleThreshold.
+// It returns true when the event must not be dispatched, along with the error to report for its record.
+func checkStale(ctx context.Context, event *digimodel.StreamEventRequest, record source.Record) (bool, error) {
+	if staleThreshold <= 0 {
+		return false, nil
+	}
+	// Events without a creation time are aged from when they reached the stream
+	created := eventCreatedAt(event)
+	if created.IsZero() {
+		created = record.ArrivedAt
+	}
+	age := lag.Between(created, time.Now())
+	if age < staleThreshold {
+		return false, nil
+	}
+
+	loggerFrom(ctx).Warn("Event is stale", zap.Duration("age", age), zap.Stringer("policy", stalePolicy))
+	switch stalePolicy {
+	case lag.PolicySkip:
+		return true, nil
+	case lag.PolicyDeadLetter:
+		return true, batch.Permanent(fmt.Errorf("%w: age %s exceeds %s", lag.ErrStale, age, staleThreshold))
+	default:
+		return false, nil
+	}
+}
+
+// reportLag logs the lag percentiles of the batch and records every lag as a metric value
+func reportLag(ctx context.Context, recorder *metrics.Recorder, summaries []recordSummary) {
+	var producerLags, streamLags []time.Duration
+	for i := range summaries {
+		summary := &summaries[i]
+		if summary.producerLag > 0 {
+			producerLags = append(producerLags, summary.producerLag)
+			recorder.Duration(metrics.ProducerToStreamLag, summary.dimensions(""), summary.producerLag)
+		}
+		if summary.streamLag > 0 {
+			streamLags = append(streamLags, summary.streamLag)
+			recorder.Duration(metrics.StreamToProcessedLag, summary.dimensions(""), summary.streamLag)
+		}
+	}
+	if len(producerLags) == 0 && len(streamLags) == 0 {
+		return
+	}
+	loggerFrom(ctx).Info("Batch lag",
+		zap.Object("producerToStream", lag.Summarize(producerLags)),
+		zap.Object("streamToProcessed", lag.Summarize(streamLags)))
+}
+
+// loadStalePolicy reads StaleEventThreshold and StaleEventPolicy from the environment
+func loadStalePolicy() (time.Duration, lag.Policy, error) {
+	threshold, err := durationFromEnv("StaleEventThreshold", 0)
+	if err != nil {
+		return 0, lag.PolicyProcess, err
+	}
+	return threshold, lag.PolicyFromString(os.Getenv("StaleEventPolicy")), nil
+}
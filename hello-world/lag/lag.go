@@ -0,0 +1,101 @@
+package lag
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrStale is wrapped by the error returned for events older than the staleness threshold under PolicyDeadLetter
+var ErrStale = errors.New("stream event is stale")
+
+// Policy decides what happens to an event older than the staleness threshold
+type Policy int
+
+// Acceptable `Policy` values
+const (
+	// PolicyProcess processes stale events like any other, they are only logged
+	PolicyProcess Policy = iota
+	// PolicySkip treats stale events as successfully processed without dispatching them
+	PolicySkip
+	// PolicyDeadLetter reports stale events as permanent failures so they are dead-lettered
+	PolicyDeadLetter
+)
+
+var policy_name = map[Policy]string{
+	PolicyProcess:    "process",
+	PolicySkip:       "skip",
+	PolicyDeadLetter: "deadletter",
+}
+
+var policy_value = map[string]Policy{
+	"process":    PolicyProcess,
+	"skip":       PolicySkip,
+	"deadletter": PolicyDeadLetter,
+}
+
+// PolicyFromString converts a string into a Policy.
+// If p is not a valid Policy then PolicyProcess will be returned
+func PolicyFromString(p string) Policy {
+	return policy_value[strings.TrimSpace(strings.ToLower(p))]
+}
+
+func (p Policy) String() string {
+	return policy_name[p]
+}
+
+// Between returns how long it took to get from start to end.
+// Clock skew between producers can make end appear before start, which is reported as no lag.
+func Between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// Summary describes the distribution of the lags of a batch
+type Summary struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Summarize returns the percentiles of lags using the nearest rank method
+func Summarize(lags []time.Duration) Summary {
+	if len(lags) == 0 {
+		return Summary{}
+	}
+	sorted := append([]time.Duration(nil), lags...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return Summary{
+		Count: len(sorted),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P99:   percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the smallest value that at least p percent of sorted are less than or equal to
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (s Summary) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("count", s.Count)
+	enc.AddDuration("p50", s.P50)
+	enc.AddDuration("p90", s.P90)
+	enc.AddDuration("p99", s.P99)
+	enc.AddDuration("max", s.Max)
+	return nil
+}
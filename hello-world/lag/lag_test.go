@@ -0,0 +1,53 @@
+package lag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	var lags []time.Duration
+	for i := 100; i >= 1; i-- {
+		lags = append(lags, time.Duration(i)*time.Millisecond)
+	}
+
+	summary := Summarize(lags)
+	if summary.Count != 100 || summary.P50 != 50*time.Millisecond || summary.P90 != 90*time.Millisecond ||
+		summary.P99 != 99*time.Millisecond || summary.Max != 100*time.Millisecond {
+		t.Fatalf("Unexpected summary %+v", summary)
+	}
+	if lags[0] != 100*time.Millisecond {
+		t.Fatal("Expected the lags not to be sorted in place")
+	}
+
+	t.Run("Small batches", func(t *testing.T) {
+		summary := Summarize([]time.Duration{3 * time.Second, time.Second})
+		if summary.P50 != time.Second || summary.P99 != 3*time.Second {
+			t.Fatalf("Unexpected summary %+v", summary)
+		}
+		if Summarize(nil) != (Summary{}) {
+			t.Fatal("Expected an empty summary")
+		}
+	})
+}
+
+func TestBetween(t *testing.T) {
+	now := time.Now()
+	if got := Between(now.Add(-time.Second), now); got != time.Second {
+		t.Fatalf("Expected 1s, got %s", got)
+	}
+	if got := Between(now, now.Add(-time.Second)); got != 0 {
+		t.Fatalf("Expected clock skew to report no lag, got %s", got)
+	}
+	if got := Between(time.Time{}, now); got != 0 {
+		t.Fatalf("Expected a missing timestamp to report no lag, got %s", got)
+	}
+}
+
+func TestPolicyFromString(t *testing.T) {
+	for s, want := range map[string]Policy{"": PolicyProcess, " Skip ": PolicySkip, "deadletter": PolicyDeadLetter, "drop": PolicyProcess} {
+		if got := PolicyFromString(s); got != want {
+			t.Fatalf("Expected %q to be %s, got %s", s, want, got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"hello-world/digimodel"
+	"hello-world/source"
+)
+
+// logger is the base logger, it is built from the environment on cold start
+var logger = zap.NewNop()
+
+// logRawRecords enables debug lines with the redacted raw payload of every record
+var logRawRecords bool
+
+type loggerKey struct{}
+
+// withLogger returns a copy of ctx carrying l
+func withLogger(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerFrom returns the logger carried by ctx, or the base logger when there is none
+func loggerFrom(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return logger
+}
+
+// invocationLogger adds the Lambda request ID to the base logger
+func invocationLogger(ctx context.Context) *zap.Logger {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		return logger.With(zap.String("requestId", lc.AwsRequestID))
+	}
+	return logger
+}
+
+// recordLogger adds the position of record in its stream or queue to the invocation logger carried by ctx
+func recordLogger(ctx context.Context, record source.Record) *zap.Logger {
+	log := sourceLogger(ctx, record)
+	if record.Envelope {
+		log = log.With(zap.Int("eventIndex", record.EventIndex))
+	}
+	return log
+}
+
+// sourceLogger adds the fields that locate record in its event source to the logger carried by ctx
+func sourceLogger(ctx context.Context, record source.Record) *zap.Logger {
+	switch record.EventSource {
+	case source.EventSourceSQS:
+		return loggerFrom(ctx).With(
+			zap.String("queueArn", record.Partition),
+			zap.String("messageId", record.ID),
+			zap.String("messageGroupId", record.Key),
+		)
+	case source.EventSourceDynamoDB:
+		return loggerFrom(ctx).With(
+			zap.String("streamArn", record.Partition),
+			zap.String("sequenceNumber", record.ID),
+			zap.String("itemKey", record.Key),
+		)
+	case source.EventSourceKafka:
+		return loggerFrom(ctx).With(
+			zap.String("topic", record.Kafka.Topic),
+			zap.Int64("partition", record.Kafka.Partition),
+			zap.Int64("offset", record.Kafka.Offset),
+		)
+	default:
+		log := loggerFrom(ctx).With(
+			zap.String("shardId", record.Partition),
+			zap.String("sequenceNumber", record.ID),
+			zap.String("partitionKey", record.Key),
+		)
+		if record.Aggregated {
+			log = log.With(zap.Int("subSequenceNumber", record.SubSequenceNumber))
+		}
+		return log
+	}
+}
+
+// newLogger builds the logger configured by the environment.
+// LogLevel sets the minimum level, such as "debug" or "warn", and defaults to debug when DebugLogging is true and info otherwise.
+// LogEncoding selects "json", the default, or "console".
+func newLogger() (*zap.Logger, error) {
+	level := zapcore.InfoLevel
+	if debug, _ := strconv.ParseBool(os.Getenv("DebugLogging")); debug {
+		level = zapcore.DebugLevel
+	}
+	if value := os.Getenv("LogLevel"); value != "" {
+		if err := level.UnmarshalText([]byte(strings.ToLower(value))); err != nil {
+			return nil, fmt.Errorf("invalid LogLevel %q", value)
+		}
+	}
+
+	config := zap.NewProductionConfig()
+	config.Level = zap.NewAtomicLevelAt(level)
+	config.EncoderConfig.TimeKey = "time"
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	// Sampling would drop record lines that share a message
+	config.Sampling = nil
+	switch encoding := strings.TrimSpace(strings.ToLower(os.Getenv("LogEncoding"))); encoding {
+	case "", "json":
+	case "console":
+		config.Encoding = "console"
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	default:
+		return nil, fmt.Errorf("invalid LogEncoding %q", encoding)
+	}
+	return config.Build()
+}
+
+// loadRedaction configures how personal data is redacted from logs and reports whether LogRawRecords is set.
+// RedactionPolicy overrides the pii struct tags of the model, see digimodel.ParsePolicy,
+// and RedactionHashKey keys the hashes of redacted values.
+func loadRedaction() (bool, error) {
+	policy, err := digimodel.ParsePolicy(os.Getenv("RedactionPolicy"))
+	if err != nil {
+		return false, err
+	}
+	digimodel.DefaultRedactor = digimodel.NewRedactor(policy, []byte(os.Getenv("RedactionHashKey")))
+
+	value := os.Getenv("LogRawRecords")
+	if value == "" {
+		return false, nil
+	}
+	raw, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid LogRawRecords %q", value)
+	}
+	return raw, nil
+}
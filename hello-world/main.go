@@ -6,80 +6,312 @@ import (
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"hello-world/batch"
 	"hello-world/digimodel"
+	"hello-world/dispatch"
+	"hello-world/lag"
+	"hello-world/metrics"
+	"hello-world/persister"
+	"hello-world/source"
+	"hello-world/tracing"
 	"log"
+	"os"
 	"strings"
+	"time"
 )
 
-// Lambda handler function
-func handler(ctx context.Context, kinesisEvent events.KinesisEvent) (map[string]interface{}, error) {
-	var kinesisBatchResponse map[string]interface{}
-	var batchItemFailures []map[string]interface{}
+// recordProcessor runs processRecord over a batch. It is configured from the environment on cold start:
+// BatchFailureMode sets the Mode, DeadlineSafetyMargin the SafetyMargin and RecordConcurrency the Concurrency.
+var recordProcessor = batch.Processor{
+	Mode:         batch.ContinueOnFailure,
+	SafetyMargin: batch.DefaultSafetyMargin,
+	Concurrency:  1,
+}
+
+// recordOrderingKey derives the key that records must be processed in order by
+var recordOrderingKey = partitionKey
+
+// dynamoDBImageAttribute is the NewImage attribute holding the event, it is set by DynamoDBImageAttribute
+var dynamoDBImageAttribute = source.DefaultDynamoDBAttribute
+
+// classifyError decides whether a failed record is retried or routed to the poison record path
+var classifyError = batch.DefaultClassifier
+
+// Lambda handler function for Kinesis streams.
+// A failed record leaves the rest of its ordering key unprocessed, so a later event of the key is not marked completed
+// before an earlier one is applied, which would leave the older status in place once the failed record is retried.
+func handler(ctx context.Context, kinesisEvent events.KinesisEvent) (events.KinesisEventResponse, error) {
+	processor := recordProcessor
+	processor.SkipKeyAfterFailure = true
+	return processBatch(ctx, processor, source.FromKinesis(kinesisEvent)).KinesisEventResponse(), nil
+}
+
+// sqsHandler is the Lambda handler function for SQS queues.
+// A failed message leaves the rest of its message group unprocessed, so FIFO queues keep every group in order.
+func sqsHandler(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	processor := recordProcessor
+	processor.SkipKeyAfterFailure = true
+	return processBatch(ctx, processor, source.FromSQS(sqsEvent)).SQSEventResponse(), nil
+}
+
+// dynamoDBHandler is the Lambda handler function for DynamoDB Streams.
+// Like Kinesis, a failed record leaves the rest of its ordering key unprocessed.
+func dynamoDBHandler(ctx context.Context, dynamoDBEvent events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	processor := recordProcessor
+	processor.SkipKeyAfterFailure = true
+	records := source.FromDynamoDB(dynamoDBEvent, dynamoDBImageAttribute)
+	return processBatch(ctx, processor, records).DynamoDBEventResponse(), nil
+}
 
-	// no records to process, so just break out early
-	if len(kinesisEvent.Records) == 0 {
-		return kinesisBatchResponse, nil
+// kafkaHandler is the Lambda handler function for Kafka topics on Amazon MSK or a self-managed cluster.
+// Kafka has no partial batch response, so any failed record fails the invocation and Lambda retries the whole batch.
+// A failed record leaves the rest of its partition unprocessed so offsets are applied in order,
+// and the idempotency store skips the records that already completed when the batch is retried.
+func kafkaHandler(ctx context.Context, kafkaEvent events.KafkaEvent) error {
+	processor := recordProcessor
+	processor.SkipKeyAfterFailure = true
+	records := source.FromKafka(kafkaEvent)
+	result := processBatch(ctx, processor, records)
+	if failures := len(result.Failures()); failures > 0 {
+		return fmt.Errorf("%d of %d records failed, retrying the batch", failures, len(records))
 	}
+	return nil
+}
 
-	for _, record := range kinesisEvent.Records {
-		curRecordSequenceNumber := ""
+// handlerFromString selects the handler for the event source named by the EventSource environment variable
+func handlerFromString(s string) (any, error) {
+	switch strings.TrimSpace(strings.ToLower(s)) {
+	case "", "kinesis":
+		return handler, nil
+	case "sqs":
+		return sqsHandler, nil
+	case "dynamodb":
+		return dynamoDBHandler, nil
+	case "kafka":
+		return kafkaHandler, nil
+	case "firehose":
+		return firehoseHandler, nil
+	default:
+		return nil, fmt.Errorf("invalid EventSource %q", s)
+	}
+}
 
-		// Begin processing events
-		//if event.EventObject == digimodel.EventObject_Case && event.EventType == digimodel.EventType_CaseStatusChanged
-		err := processRecord(record)
-		if err != nil {
-			log.Printf("Failed to process record: %v", err)
-			curRecordSequenceNumber = record.Kinesis.SequenceNumber
-			batchItemFailures = append(batchItemFailures, map[string]interface{}{"itemIdentifier": curRecordSequenceNumber})
-		}
+// processBatch runs processRecord over a batch from any event source and returns the records to report as failed
+func processBatch(ctx context.Context, processor batch.Processor, sourceRecords []source.Record) *batch.Result {
+	log := invocationLogger(ctx)
+	ctx = withLogger(ctx, log)
+	ctx, span := startInvocationSpan(ctx, len(sourceRecords))
+	// Lambda may freeze the environment as soon as the handler returns, so spans are flushed first
+	defer flushTraces(ctx)
+	defer span.End()
 
-		// Add a condition to check if the record processing failed
-		//if curRecordSequenceNumber != "" {
-		//	batchItemFailures = append(batchItemFailures, map[string]interface{}{"itemIdentifier": curRecordSequenceNumber})
-		//}
+	// Compressed Kinesis records and envelopes are unpacked first, so every event is ordered, processed and reported on its own
+	sourceRecords = unpackRecords(sourceRecords)
+	records := make([]batch.Record, len(sourceRecords))
+	for i, record := range sourceRecords {
+		records[i] = batch.Record{ID: record.ID, Key: recordOrderingKey(record)}
 	}
 
-	kinesisBatchResponse = map[string]interface{}{
-		"batchItemFailures": batchItemFailures,
+	recorder := metrics.NewRecorder(metricNamespace, metricDimensionSets)
+	summaries := make([]recordSummary, len(records))
+
+	result := processor.Process(ctx, records, func(ctx context.Context, i int) error {
+		record := sourceRecords[i]
+		ctx = withLogger(ctx, recordLogger(ctx, record))
+		ctx, span := startRecordSpan(ctx, record)
+		summary := &summaries[i]
+		summary.started = true
+		start := time.Now()
+		defer func() {
+			recorder.Duration(metrics.RecordLatency, summary.dimensions(""), time.Since(start))
+			summary.streamLag = lag.Between(record.ArrivedAt, time.Now())
+		}()
+
+		err := processRecord(ctx, record, summary)
+		if err == nil {
+			recordAttempts.Forget(record.UniqueID())
+			markSubRecordCompleted(ctx, record)
+			summary.record(recorder, "", nil)
+			endRecordSpan(span, summary.outcome(), "", nil)
+			return nil
+		}
+		// Permanent failures would be retried forever, so they are dead-lettered instead of reported
+		if reason, ok := routeFailedRecord(ctx, record, err); ok {
+			markSubRecordCompleted(ctx, record)
+			summary.record(recorder, string(reason), nil)
+			endRecordSpan(span, "deadlettered", string(reason), err)
+			return nil
+		}
+		summary.record(recorder, failureReason(ctx, err), err)
+		endRecordSpan(span, "failed", failureReason(ctx, err), err)
+		return err
+	})
+
+	if result.Unprocessed() > 0 {
+		log.Warn("Reporting unprocessed records for retry", zap.Int("unprocessed", result.Unprocessed()))
 	}
-	return kinesisBatchResponse, nil
+	logFailingClusters(log)
+	emitMetrics(ctx, recorder, summaries)
+	return result
 }
 
 func main() {
+	var err error
+	logger, err = newLogger()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logRawRecords, err = loadRedaction()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	recordProcessor.Mode = batch.ModeFromString(os.Getenv("BatchFailureMode"))
+	recordProcessor.SafetyMargin, err = batch.SafetyMarginFromString(os.Getenv("DeadlineSafetyMargin"))
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	recordProcessor.Concurrency, err = loadConcurrency()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	recordOrderingKey, err = orderingKeyFromString(os.Getenv("RecordOrderingKey"))
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	eventDispatcher = newEventDispatcher(dispatch.PolicyFromString(os.Getenv("UnregisteredEventPolicy")))
+	maxRecordAttempts, err = loadRetryBudget()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	deadLetterSink, err = newDeadLetterSink(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	idempotencyTTL, err = loadIdempotencyTTL()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	idempotencyStore, err = newIdempotencyStore(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	clusterResolver, err = newClusterResolver(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	err = checkClusterSource(eventDispatcher)
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	breakerConfig, err := loadBreakerConfig()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	clusterPersisters = persister.NewRegistry(newVCPersister, breakerConfig)
+	recordRules, err = loadRules()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	metricNamespace, metricDimensionSets, err = loadMetricsConfig()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	staleThreshold, stalePolicy, err = loadStalePolicy()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	tracerProvider, err = setupTracing()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	maxDecompressedSize, err = loadMaxDecompressedSize()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	if attribute := os.Getenv("DynamoDBImageAttribute"); attribute != "" {
+		dynamoDBImageAttribute = attribute
+	}
+	eventHandler, err := handlerFromString(os.Getenv("EventSource"))
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
 	// Start Lambda
-	lambda.Start(handler)
+	lambda.Start(eventHandler)
 }
 
-func processRecord(record events.KinesisEventRecord) error {
-	// Implement your record processing logic here
-	// Locate ClusterServerInfo
-	// Make Record to transform into a DigiCaseStatusUpdate record
-	// GetOrCreateClusterPersisterForTarget
-	// UpdatePersisterTargetStatus to store most recent error
-	// InsertRecords to SendCaseStatusChangedEvent to VC via GRPC
-
+// processRecord decodes and dispatches a single record, filling in summary for metrics as it goes
+func processRecord(ctx context.Context, record source.Record, summary *recordSummary) error {
 	var event digimodel.StreamEventRequest
+	log := loggerFrom(ctx)
+
+	// A retried KPL aggregated record or envelope delivers again the parts that already succeeded
+	if subRecordCompleted(ctx, record) {
+		log.Info("Skipping part of a record, it was already processed")
+		summary.skipped = true
+		return nil
+	}
 
-	// Log the raw data for debugging
-	fmt.Printf("Raw data: %s\n", string(record.Kinesis.Data))
+	// Producers may gzip or zstd compress events to save shard throughput, unpackRecords already decompressed them
+	err := checkDecompressed(ctx, record, summary)
+	if err != nil {
+		return err
+	}
+
+	// Raw payloads carry personal data, so they are only logged redacted and when LogRawRecords is set
+	if logRawRecords {
+		log.Debug("Received record", zap.ByteString("data", digimodel.RedactJSON(record.Data)))
+	}
 
 	// Unmarshal the Data string into a digimodel.StreamEventRequest
-	err := json.Unmarshal(record.Kinesis.Data, &event)
+	err = json.Unmarshal(record.Data, &event)
 	if err != nil {
-		log.Printf("failed to process event due to invalid kinesis record with error: %v", err)
+		log.Error("Failed to process event due to invalid record", zap.Error(err))
 		// If event cannot be unmarshalled, there is a formatting issue with the event so do not retry
+		return batch.Permanent(fmt.Errorf("%w: %w", errDecodeFailure, err))
+	}
+
+	summary.eventObject = event.EventObject.String()
+	summary.eventType = event.EventType.String()
+	trace.SpanFromContext(ctx).SetAttributes(tracing.EventAttributes(&event)...)
+	summary.producerLag = lag.Between(eventCreatedAt(&event), record.ArrivedAt)
+
+	// Every later line for this record carries the event fields
+	log = log.With(zap.Inline(event))
+	ctx = withLogger(ctx, log)
+	log.Info("Processing event")
+	log.Debug("Decoded event", zap.Object("data", digimodel.LogSafe(&event.Data)))
+
+	// Events the consumer fell too far behind on follow StaleEventPolicy
+	if stop, err := checkStale(ctx, &event, record); stop {
+		summary.skipped = err == nil
 		return err
 	}
 
-	log.Printf("Unmarshalled event data %+v", event.Data)
+	// Tenants, business units, event types and case statuses can be excluded by RecordRules
+	if stop, err := applyRules(ctx, &event); stop {
+		summary.skipped = err == nil
+		return err
+	}
 
-	log.Printf("processing event data: %v\n", record.Kinesis.Data)
+	// Only events with a handler have side effects worth deduplicating
+	outcome := eventDispatcher.Outcome(event.EventObject, event.EventType)
+	handled := outcome == dispatch.OutcomeHandled
+	if handled && alreadyCompleted(ctx, &event) {
+		log.Info("Skipping event, it was already processed")
+		summary.skipped = true
+		return nil
+	}
+	summary.skipped = outcome == dispatch.OutcomeSkipped
 
-	//if strings.TrimSpace(strings.ToLower(event.Data.Case.Status)) == "closed" {
-	if strings.TrimSpace(strings.ToLower(string(event.Data.Brand.TenantID))) == "0" {
-		err = fmt.Errorf("failed to process event data")
-		log.Println(err)
+	err = eventDispatcher.Dispatch(ctx, &event)
+	if err != nil {
 		return err
 	}
+	if handled {
+		markCompleted(ctx, &event)
+	}
 	return nil
 }
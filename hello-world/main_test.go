@@ -1,64 +1,1272 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"net/http"
-	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"hello-world/batch"
+	"hello-world/breaker"
+	"hello-world/cluster"
+	"hello-world/compression"
+	"hello-world/deadletter"
+	"hello-world/digimodel"
+	"hello-world/dispatch"
+	"hello-world/idempotency"
+	"hello-world/lag"
+	"hello-world/metrics"
+	"hello-world/persister"
+	"hello-world/rules"
+	"hello-world/source"
+	"hello-world/tracing"
+	"hello-world/vcclient/vcfake"
+	"hello-world/vcpb"
 )
 
+// vcServer stands in for the VC cluster for every test in this package
+var vcServer *vcfake.Server
+
+// spans captures every span ended by the tests
+var spans = tracetest.NewInMemoryExporter()
+
+// metricsSink captures the metrics of every invocation instead of writing them to stdout
+var metricsSink = metrics.NewMemorySink()
+
+func TestMain(m *testing.M) {
+	vcServer = vcfake.Start()
+	vcDialOptions = vcServer.Dialer()
+	clusterResolver = cluster.NewResolver(cluster.NewStaticSource(nil, &cluster.ServerInfo{ClusterID: "fake", Endpoint: vcfake.Target}), time.Minute, time.Minute)
+	metricSink = metricsSink
+	tracerProvider = tracing.Setup(spans, true)
+
+	code := m.Run()
+	vcServer.Stop()
+	os.Exit(code)
+}
+
+func kinesisRecord(sequenceNumber string, data string) events.KinesisEventRecord {
+	return events.KinesisEventRecord{
+		EventSourceArn: "arn:aws:kinesis:us-west-2:000000000000:stream/test",
+		Kinesis: events.KinesisRecord{
+			Data:           []byte(data),
+			PartitionKey:   "partition-" + sequenceNumber,
+			SequenceNumber: sequenceNumber,
+		},
+	}
+}
+
+func streamEvent(eventID string, tenantID string) string {
+	return fmt.Sprintf(`{"eventId":"%s","eventObject":"Case","eventType":"CaseStatusChanged","data":{"brand":{"tenantId":"%s","businessUnitId":1},`+
+		`"case":{"id":"case-%[1]s","contactId":"contact-%[1]s","interactionId":"interaction-%[1]s","status":"open","routingQueueId":"queue-1","statusUpdatedAt":"2024-05-01T10:00:00Z"}}}`, eventID, tenantID)
+}
+
+func failedItems(response events.KinesisEventResponse) []string {
+	var ids []string
+	for _, failure := range response.BatchItemFailures {
+		ids = append(ids, failure.ItemIdentifier)
+	}
+	return ids
+}
+
 func TestHandler(t *testing.T) {
-	t.Run("Unable to get IP", func(t *testing.T) {
-		DefaultHTTPGetAddress = "http://127.0.0.1:12345"
+	t.Run("Empty batch", func(t *testing.T) {
+		response, err := handler(context.Background(), events.KinesisEvent{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.BatchItemFailures == nil || len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected an empty list of batch item failures, got %v", response.BatchItemFailures)
+		}
+	})
 
-		_, err := handler(events.APIGatewayProxyRequest{})
-		if err == nil {
-			t.Fatal("Error failed to trigger with an invalid request")
+	t.Run("Successful batch", func(t *testing.T) {
+		response, err := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+			kinesisRecord("1", streamEvent("a", "11")),
+			kinesisRecord("2", streamEvent("b", "12")),
+		}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
 		}
 	})
 
-	t.Run("Non 200 Response", func(t *testing.T) {
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(500)
-		}))
-		defer ts.Close()
+	t.Run("Continue on failure", func(t *testing.T) {
+		recordProcessor.Mode = batch.ContinueOnFailure
+
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+			kinesisRecord("1", streamEvent("a", "11")),
+			kinesisRecord("2", streamEvent("b", "0")),
+			kinesisRecord("3", streamEvent("c", "13")),
+			kinesisRecord("4", streamEvent("d", "0")),
+		}})
+		if got := fmt.Sprint(failedItems(response)); got != "[2 4]" {
+			t.Fatalf("Expected failures [2 4], got %s", got)
+		}
+	})
 
-		DefaultHTTPGetAddress = ts.URL
+	t.Run("Stop on first failure", func(t *testing.T) {
+		recordProcessor.Mode = batch.StopOnFirstFailure
+		defer func() { recordProcessor.Mode = batch.ContinueOnFailure }()
 
-		_, err := handler(events.APIGatewayProxyRequest{})
-		if err != nil && err.Error() != ErrNon200Response.Error() {
-			t.Fatalf("Error failed to trigger with an invalid HTTP response: %v", err)
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+			kinesisRecord("1", streamEvent("a", "11")),
+			kinesisRecord("2", streamEvent("b", "0")),
+			kinesisRecord("3", streamEvent("c", "13")),
+			kinesisRecord("4", streamEvent("d", "14")),
+		}})
+		if got := fmt.Sprint(failedItems(response)); got != "[2 3 4]" {
+			t.Fatalf("Expected failures [2 3 4], got %s", got)
 		}
 	})
+}
+
+func sqsMessage(messageID string, groupID string, body string) events.SQSMessage {
+	message := events.SQSMessage{
+		MessageId:      messageID,
+		Body:           body,
+		EventSource:    "aws:sqs",
+		EventSourceARN: "arn:aws:sqs:us-west-2:000000000000:test",
+		Attributes:     map[string]string{},
+	}
+	if groupID != "" {
+		message.EventSourceARN += ".fifo"
+		message.Attributes["MessageGroupId"] = groupID
+	}
+	return message
+}
 
-	t.Run("Unable decode IP", func(t *testing.T) {
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(500)
-		}))
-		defer ts.Close()
+func TestSQSHandler(t *testing.T) {
+	t.Run("FIFO queue keeps message groups in order", func(t *testing.T) {
+		response, err := sqsHandler(context.Background(), events.SQSEvent{Records: []events.SQSMessage{
+			sqsMessage("m1", "group-1", streamEvent("sqs-1", "0")),
+			sqsMessage("m2", "group-2", streamEvent("sqs-2", "11")),
+			sqsMessage("m3", "group-1", streamEvent("sqs-3", "11")),
+		}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var ids []string
+		for _, failure := range response.BatchItemFailures {
+			ids = append(ids, failure.ItemIdentifier)
+		}
+		if got := fmt.Sprint(ids); got != "[m1 m3]" {
+			t.Fatalf("Expected failures [m1 m3], got %s", got)
+		}
+	})
 
-		DefaultHTTPGetAddress = ts.URL
+	t.Run("Standard queue", func(t *testing.T) {
+		response, _ := sqsHandler(context.Background(), events.SQSEvent{Records: []events.SQSMessage{
+			sqsMessage("m1", "", streamEvent("sqs-4", "0")),
+			sqsMessage("m2", "", streamEvent("sqs-5", "11")),
+		}})
+		if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "m1" {
+			t.Fatalf("Expected failures [m1], got %+v", response.BatchItemFailures)
+		}
+	})
 
-		_, err := handler(events.APIGatewayProxyRequest{})
+	t.Run("Poison messages are dead-lettered", func(t *testing.T) {
+		sink := deadletter.NewMemorySink()
+		deadLetterSink = sink
+		defer func() { deadLetterSink = nil }()
+
+		response, _ := sqsHandler(context.Background(), events.SQSEvent{Records: []events.SQSMessage{sqsMessage("m1", "group-1", "not json")}})
+		if response.BatchItemFailures == nil || len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected an empty list of batch item failures, got %+v", response.BatchItemFailures)
+		}
+		entries := sink.Entries()
+		if len(entries) != 1 || entries[0].EventSource != "aws:sqs" || entries[0].SequenceNumber != "m1" || entries[0].PartitionKey != "group-1" {
+			t.Fatalf("Unexpected dead letter entries %+v", entries)
+		}
+	})
+}
+
+func TestHandlerUnpacksOnlyKinesisRecords(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	defer func() { deadLetterSink = nil }()
+
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	w.Write([]byte(streamEvent("unpack-1", "11")))
+	w.Close()
+
+	array := "[" + streamEvent("unpack-2", "11") + "," + streamEvent("unpack-3", "11") + "]"
+
+	response, _ := sqsHandler(context.Background(), events.SQSEvent{Records: []events.SQSMessage{
+		sqsMessage("m1", "", gzipped.String()),
+		sqsMessage("m2", "", array),
+	}})
+	if len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no batch item failures, got %+v", response.BatchItemFailures)
+	}
+	entries := sink.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected both SQS messages to be dead-lettered, got %+v", entries)
+	}
+	if entries[0].SequenceNumber != "m1" || entries[0].Reason != deadletter.ReasonDecodeFailure || !bytes.Equal(entries[0].Data, gzipped.Bytes()) {
+		t.Fatalf("Expected the compressed SQS message to be dead-lettered as it was delivered, got %+v", entries[0])
+	}
+	if entries[1].SequenceNumber != "m2" || entries[1].Reason != deadletter.ReasonDecodeFailure || string(entries[1].Data) != array {
+		t.Fatalf("Expected the SQS message holding an array to be dead-lettered whole, got %+v", entries[1])
+	}
+}
+
+func dynamoDBRecord(sequenceNumber string, image map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventName:      "INSERT",
+		EventSource:    "aws:dynamodb",
+		EventSourceArn: "arn:aws:dynamodb:us-west-2:000000000000:table/test/stream/1",
+		Change: events.DynamoDBStreamRecord{
+			Keys:           map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute(sequenceNumber)},
+			NewImage:       image,
+			SequenceNumber: sequenceNumber,
+		},
+	}
+}
+
+func TestDynamoDBHandler(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	defer func() { deadLetterSink = nil }()
+	str := events.NewStringAttribute
+	attributes := map[string]events.DynamoDBAttributeValue{
+		"eventId":     str("ddb-2"),
+		"eventObject": str("Case"),
+		"eventType":   str("CaseStatusChanged"),
+		"data": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+			"brand": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+				"tenantId":       str("11"),
+				"businessUnitId": events.NewNumberAttribute("1"),
+			}),
+			"case": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+				"id":              str("case-ddb-2"),
+				"contactId":       str("contact-ddb-2"),
+				"interactionId":   str("interaction-ddb-2"),
+				"status":          str("open"),
+				"routingQueueId":  str("queue-1"),
+				"statusUpdatedAt": str("2024-05-01T10:00:00Z"),
+			}),
+		}),
+	}
+
+	response, err := dynamoDBHandler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		dynamoDBRecord("1", map[string]events.DynamoDBAttributeValue{"event": str(streamEvent("ddb-1", "11"))}),
+		dynamoDBRecord("2", attributes),
+		dynamoDBRecord("3", map[string]events.DynamoDBAttributeValue{"event": str(streamEvent("ddb-3", "0"))}),
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "3" {
+		t.Fatalf("Expected failures [3], got %+v", response.BatchItemFailures)
+	}
+	if entries := sink.Entries(); len(entries) != 0 {
+		t.Fatalf("Expected both images to decode, got dead letter entries %+v", entries)
+	}
+}
+
+func kafkaRecord(partition int64, offset int64, data string) events.KafkaRecord {
+	return events.KafkaRecord{Topic: "cases", Partition: partition, Offset: offset, Value: base64.StdEncoding.EncodeToString([]byte(data))}
+}
+
+func TestKafkaHandler(t *testing.T) {
+	t.Run("Successful batch", func(t *testing.T) {
+		err := kafkaHandler(context.Background(), events.KafkaEvent{Records: map[string][]events.KafkaRecord{
+			"cases-0": {kafkaRecord(0, 1, streamEvent("kafka-1", "11")), kafkaRecord(0, 2, streamEvent("kafka-2", "12"))},
+		}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Failure keeps partition order", func(t *testing.T) {
+		metricsSink.Reset()
+		err := kafkaHandler(context.Background(), events.KafkaEvent{Records: map[string][]events.KafkaRecord{
+			"cases-0": {kafkaRecord(0, 3, streamEvent("kafka-3", "0")), kafkaRecord(0, 4, streamEvent("kafka-4", "11"))},
+			"cases-1": {kafkaRecord(1, 1, streamEvent("kafka-5", "11"))},
+		}})
 		if err == nil {
-			t.Fatal("Error failed to trigger with an invalid HTTP response")
+			t.Fatal("Expected the batch to fail")
+		}
+		if got := metricsSink.Sum(metrics.RecordsSucceeded, metrics.Dimensions{}); got != 1 {
+			t.Fatalf("Expected only the other partition to succeed, got %v", got)
+		}
+		if got := metricsSink.Sum(metrics.RecordsFailed, metrics.Dimensions{metrics.DimensionReason: "Unprocessed"}); got != 1 {
+			t.Fatalf("Expected offset 4 to be left unprocessed, got %v", got)
 		}
 	})
 
-	t.Run("Successful Request", func(t *testing.T) {
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(200)
-			fmt.Fprintf(w, "127.0.0.1")
-		}))
-		defer ts.Close()
+	t.Run("Record context", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		base := logger
+		logger = zap.New(core)
+		defer func() { logger = base }()
+
+		kafkaHandler(context.Background(), events.KafkaEvent{Records: map[string][]events.KafkaRecord{
+			"cases-2": {kafkaRecord(2, 9, streamEvent("kafka-6", "11"))},
+		}})
+		entries := logs.FilterMessage("Processing event").All()
+		if len(entries) != 1 {
+			t.Fatalf("Expected 1 processing line, got %d", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["topic"] != "cases" || fields["partition"] != int64(2) || fields["offset"] != int64(9) {
+			t.Fatalf("Expected topic, partition and offset fields, got %v", fields)
+		}
+	})
+}
+
+func TestFirehoseHandler(t *testing.T) {
+	metricsSink.Reset()
+	sensitive := strings.Replace(streamEvent("firehose-1", "11"), `"data":{`, `"data":{"user":{"id":7,"emailAddress":"agent@example.com"},`, 1)
+	invalid := strings.Replace(streamEvent("firehose-4", "11"), `"status":"open"`, `"status":""`, 1)
+	invalidRejected := strings.Replace(streamEvent("firehose-5", "0"), `"status":"open"`, `"status":""`, 1)
+
+	response, err := firehoseHandler(context.Background(), events.KinesisFirehoseEvent{Records: []events.KinesisFirehoseEventRecord{
+		{RecordID: "r1", Data: []byte(sensitive)},
+		{RecordID: "r2", Data: []byte(streamEvent("firehose-2", "0"))},
+		{RecordID: "r3", Data: []byte("not json")},
+		{RecordID: "r4", Data: []byte(invalid)},
+		{RecordID: "r5", Data: []byte(invalidRejected)},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 
-		DefaultHTTPGetAddress = ts.URL
+	var results []string
+	for _, record := range response.Records {
+		results = append(results, record.RecordID+"="+record.Result)
+	}
+	if got := strings.Join(results, " "); got != "r1=Ok r2=Dropped r3=ProcessingFailed r4=ProcessingFailed r5=Dropped" {
+		t.Fatalf("Unexpected results %s", got)
+	}
 
-		_, err := handler(events.APIGatewayProxyRequest{})
+	t.Run("Output is normalized and redacted JSON", func(t *testing.T) {
+		output := string(response.Records[0].Data)
+		if !strings.HasSuffix(output, "}\n") || strings.Count(output, "\n") != 1 {
+			t.Fatalf("Expected a single line of JSON, got %q", output)
+		}
+		var event digimodel.StreamEventRequest
+		if err := json.Unmarshal([]byte(output), &event); err != nil || event.EventID != "firehose-1" {
+			t.Fatalf("Expected the output to decode, got %v: %s", err, output)
+		}
+		if strings.Contains(output, "agent@example.com") || !strings.Contains(output, `"statusUpdatedAt":"2024-05-01T10:00:00Z"`) {
+			t.Fatalf("Expected the output to be redacted and normalized, got %s", output)
+		}
+	})
+
+	t.Run("Failed records keep their original data", func(t *testing.T) {
+		if got := string(response.Records[2].Data); got != "not json" {
+			t.Fatalf("Expected the original data, got %q", got)
+		}
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		for _, test := range []struct {
+			name string
+			dims metrics.Dimensions
+			want float64
+		}{
+			{metrics.RecordsSucceeded, metrics.Dimensions{}, 1},
+			{metrics.RecordsSkipped, metrics.Dimensions{}, 2},
+			{metrics.RecordsFailed, metrics.Dimensions{metrics.DimensionReason: string(deadletter.ReasonDecodeFailure)}, 1},
+			{metrics.RecordsFailed, metrics.Dimensions{metrics.DimensionReason: string(deadletter.ReasonValidationFailure)}, 1},
+		} {
+			if got := metricsSink.Sum(test.name, test.dims); got != test.want {
+				t.Fatalf("Expected %s %v to be %v, got %v", test.name, test.dims, test.want, got)
+			}
+		}
+	})
+}
+
+func TestHandlerFromString(t *testing.T) {
+	for _, name := range []string{"", "kinesis", "SQS", "dynamodb", "kafka", "firehose"} {
+		if _, err := handlerFromString(name); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", name, err)
+		}
+	}
+	if _, err := handlerFromString("mq"); err == nil {
+		t.Fatal("Expected an error for an unknown event source")
+	}
+}
+
+// aggregatedRecord packs data into a KPL aggregated record, every user record sharing the partition key of the record
+func aggregatedRecord(sequenceNumber string, data ...string) events.KinesisEventRecord {
+	body := protowire.AppendTag(nil, 1, protowire.BytesType)
+	body = protowire.AppendString(body, "partition-"+sequenceNumber)
+	for _, d := range data {
+		var record []byte
+		record = protowire.AppendTag(record, 1, protowire.VarintType)
+		record = protowire.AppendVarint(record, 0)
+		record = protowire.AppendTag(record, 3, protowire.BytesType)
+		record = protowire.AppendString(record, d)
+		body = protowire.AppendTag(body, 3, protowire.BytesType)
+		body = protowire.AppendBytes(body, record)
+	}
+	digest := md5.Sum(body)
+	return kinesisRecord(sequenceNumber, string(append(append([]byte{0xF3, 0x89, 0x9A, 0xC2}, body...), digest[:]...)))
+}
+
+func TestHandlerAggregatedRecords(t *testing.T) {
+	event := events.KinesisEvent{Records: []events.KinesisEventRecord{
+		// The failing user record comes last, as a failure leaves the later user records of its partition key unprocessed
+		aggregatedRecord("1", streamEvent("kpl-1", "11"), streamEvent("kpl-3", "11"), streamEvent("kpl-2", "0")),
+		kinesisRecord("2", streamEvent("kpl-4", "11")),
+	}}
+
+	metricsSink.Reset()
+	response, _ := handler(context.Background(), event)
+	if got := fmt.Sprint(failedItems(response)); got != "[1]" {
+		t.Fatalf("Expected the aggregated record to be reported once, got %s", got)
+	}
+	if got := metricsSink.Sum(metrics.RecordsReceived, metrics.Dimensions{}); got != 4 {
+		t.Fatalf("Expected every user record to be received, got %v", got)
+	}
+
+	t.Run("Retry skips user records that succeeded", func(t *testing.T) {
+		metricsSink.Reset()
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: event.Records[:1]})
+		if got := fmt.Sprint(failedItems(response)); got != "[1]" {
+			t.Fatalf("Expected failures [1], got %s", got)
+		}
+		if got := metricsSink.Sum(metrics.RecordsSkipped, metrics.Dimensions{}); got != 2 {
+			t.Fatalf("Expected 2 skipped user records, got %v", got)
+		}
+	})
+}
+
+func TestHandlerCompressedRecords(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	defer func() { deadLetterSink = nil }()
+	metricsSink.Reset()
+
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	w.Write([]byte(streamEvent("gzip-1", "11")))
+	w.Close()
+	encoder, _ := zstd.NewWriter(nil)
+	defer encoder.Close()
+	zstded := encoder.EncodeAll([]byte(streamEvent("zstd-1", "11")), nil)
+
+	maxDecompressedSize = 1 << 10
+	defer func() { maxDecompressedSize = compression.DefaultMaxSize }()
+	bomb := encoder.EncodeAll(bytes.Repeat([]byte(" "), 1<<20), nil)
+
+	response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", gzipped.String()),
+		kinesisRecord("2", string(zstded)),
+		kinesisRecord("3", string(bomb)),
+	}})
+	if len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+	}
+	if got := metricsSink.Sum(metrics.RecordsSucceeded, metrics.Dimensions{}); got != 2 {
+		t.Fatalf("Expected both compressed records to succeed, got %v", got)
+	}
+	entries := sink.Entries()
+	if len(entries) != 1 || entries[0].Reason != deadletter.ReasonPayloadTooLarge || entries[0].SequenceNumber != "3" {
+		t.Fatalf("Expected the decompression bomb to be dead-lettered, got %+v", entries)
+	}
+	ratios := metricsSink.Values(metrics.CompressionRatio, metrics.Dimensions{})
+	if len(ratios) != 2 || ratios[0] <= 1 || ratios[1] <= 1 {
+		t.Fatalf("Expected 2 compression ratios above 1, got %v", ratios)
+	}
+}
+
+func TestHandlerEnvelopes(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	defer func() { deadLetterSink = nil }()
+
+	// The failing event comes last, as a failure leaves the later events of its partition key unprocessed
+	array := "[" + streamEvent("envelope-1", "11") + "," + streamEvent("envelope-3", "11") + "," + streamEvent("envelope-2", "0") + "]"
+	ndjson := streamEvent("envelope-4", "11") + "\n" + "not json" + "\n" + streamEvent("envelope-5", "12")
+	event := events.KinesisEvent{Records: []events.KinesisEventRecord{kinesisRecord("1", array), kinesisRecord("2", ndjson)}}
+
+	metricsSink.Reset()
+	response, _ := handler(context.Background(), event)
+	if got := fmt.Sprint(failedItems(response)); got != "[1]" {
+		t.Fatalf("Expected only the envelope with a retryable failure to be reported, got %s", got)
+	}
+	if got := metricsSink.Sum(metrics.RecordsReceived, metrics.Dimensions{}); got != 6 {
+		t.Fatalf("Expected every event to be received, got %v", got)
+	}
+	entries := sink.Entries()
+	if len(entries) != 1 || entries[0].SequenceNumber != "2/1" || string(entries[0].Data) != "not json" {
+		t.Fatalf("Expected only the invalid line to be dead-lettered, got %+v", entries)
+	}
+
+	t.Run("Redelivery skips events that succeeded", func(t *testing.T) {
+		metricsSink.Reset()
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: event.Records[:1]})
+		if got := fmt.Sprint(failedItems(response)); got != "[1]" {
+			t.Fatalf("Expected failures [1], got %s", got)
+		}
+		if got := metricsSink.Sum(metrics.RecordsSkipped, metrics.Dimensions{}); got != 2 {
+			t.Fatalf("Expected 2 skipped events, got %v", got)
+		}
+	})
+
+	t.Run("Truncated event is dead-lettered whole", func(t *testing.T) {
+		truncated := "{\n  \"eventId\": \"envelope-8\",\n  \"eventType\": "
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{kinesisRecord("4", truncated)}})
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+		}
+		entries := sink.Entries()
+		if last := entries[len(entries)-1]; last.SequenceNumber != "4" || string(last.Data) != truncated {
+			t.Fatalf("Expected the truncated event to be dead-lettered whole, got %+v", last)
+		}
+	})
+
+	t.Run("Compressed envelope", func(t *testing.T) {
+		metricsSink.Reset()
+		var gzipped bytes.Buffer
+		w := gzip.NewWriter(&gzipped)
+		w.Write([]byte("[" + streamEvent("envelope-6", "11") + "," + streamEvent("envelope-7", "11") + "]"))
+		w.Close()
+
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{kinesisRecord("3", gzipped.String())}})
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+		}
+		if got := metricsSink.Sum(metrics.RecordsSucceeded, metrics.Dimensions{}); got != 2 {
+			t.Fatalf("Expected both events to succeed, got %v", got)
+		}
+		if got := len(metricsSink.Values(metrics.CompressionRatio, metrics.Dimensions{})); got != 2 {
+			t.Fatalf("Expected a compression ratio for each event, got %d", got)
+		}
+	})
+}
+
+func TestHandlerPermanentFailures(t *testing.T) {
+	t.Run("Invalid JSON is not retried", func(t *testing.T) {
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+			kinesisRecord("1", "not json"),
+			kinesisRecord("2", streamEvent("b", "0")),
+		}})
+		if got := fmt.Sprint(failedItems(response)); got != "[2]" {
+			t.Fatalf("Expected failures [2], got %s", got)
+		}
+	})
+
+	t.Run("Custom classifier", func(t *testing.T) {
+		classifyError = func(err error) batch.Kind { return batch.KindRetryable }
+		defer func() { classifyError = batch.DefaultClassifier }()
+
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+			kinesisRecord("1", "not json"),
+		}})
+		if got := fmt.Sprint(failedItems(response)); got != "[1]" {
+			t.Fatalf("Expected failures [1], got %s", got)
+		}
+	})
+}
+
+func TestHandlerDeadLetters(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	recordAttempts = newAttemptCounter()
+	defer func() { deadLetterSink = nil }()
+
+	t.Run("Decode failure", func(t *testing.T) {
+		record := kinesisRecord("1", "not json")
+		record.EventID = "shardId-000000000003:1"
+
+		response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{record}})
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+		}
+
+		entries := sink.Entries()
+		if len(entries) != 1 {
+			t.Fatalf("Expected 1 dead letter entry, got %d", len(entries))
+		}
+		entry := entries[0]
+		if entry.Reason != deadletter.ReasonDecodeFailure || entry.ShardID != "shardId-000000000003" ||
+			entry.SequenceNumber != "1" || entry.PartitionKey != "partition-1" || string(entry.Data) != "not json" || entry.Attempts != 1 {
+			t.Fatalf("Unexpected dead letter entry %+v", entry)
+		}
+	})
+
+	t.Run("Retry budget exceeded", func(t *testing.T) {
+		maxRecordAttempts = 2
+		defer func() { maxRecordAttempts = 0 }()
+		event := events.KinesisEvent{Records: []events.KinesisEventRecord{kinesisRecord("7", streamEvent("a", "0"))}}
+
+		response, _ := handler(context.Background(), event)
+		if got := fmt.Sprint(failedItems(response)); got != "[7]" {
+			t.Fatalf("Expected failures [7] on the first attempt, got %s", got)
+		}
+
+		response, _ = handler(context.Background(), event)
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected the record to be dead-lettered on the second attempt, got %v", failedItems(response))
+		}
+		entries := sink.Entries()
+		if last := entries[len(entries)-1]; last.Reason != deadletter.ReasonRetryBudgetExceeded || last.Attempts != 2 {
+			t.Fatalf("Unexpected dead letter entry %+v", last)
+		}
+	})
+}
+
+func TestHandlerDeadline(t *testing.T) {
+	t.Run("Records are not started within the safety margin", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), recordProcessor.SafetyMargin/2)
+		defer cancel()
+
+		response, err := handler(ctx, events.KinesisEvent{Records: []events.KinesisEventRecord{
+			kinesisRecord("1", streamEvent("a", "11")),
+			kinesisRecord("2", streamEvent("b", "12")),
+		}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := fmt.Sprint(failedItems(response)); got != "[1 2]" {
+			t.Fatalf("Expected failures [1 2], got %s", got)
+		}
+	})
+
+	t.Run("Records are processed outside the safety margin", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		response, _ := handler(ctx, events.KinesisEvent{Records: []events.KinesisEventRecord{
+			kinesisRecord("1", streamEvent("a", "11")),
+		}})
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+		}
+	})
+}
+
+func TestHandlerConcurrency(t *testing.T) {
+	recordProcessor.Concurrency = 4
+	defer func() { recordProcessor.Concurrency = 1 }()
+
+	response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("a", "11")),
+		kinesisRecord("2", streamEvent("b", "0")),
+		kinesisRecord("3", streamEvent("c", "13")),
+		kinesisRecord("4", streamEvent("d", "0")),
+	}})
+	if got := fmt.Sprint(failedItems(response)); got != "[2 4]" {
+		t.Fatalf("Expected failures [2 4], got %s", got)
+	}
+}
+
+func TestCaseIDOrderingKey(t *testing.T) {
+	record := source.Record{ID: "1", Key: "partition-1", Data: []byte(`{"data":{"contact":{"id":"42"}}}`)}
+	if key := caseID(record); key != "case:42" {
+		t.Fatalf("Expected key case:42, got %s", key)
+	}
+	record = source.Record{ID: "2", Key: "partition-2", Data: []byte("not json")}
+	if key := caseID(record); key != "partition:partition-2" {
+		t.Fatalf("Expected key partition:partition-2, got %s", key)
+	}
+}
+
+func TestPartitionKeyOrderingKey(t *testing.T) {
+	if key := partitionKey(source.Record{ID: "1", Key: "group"}); key != "group" {
+		t.Fatalf("Expected key group, got %s", key)
+	}
+	if key := partitionKey(source.Record{ID: "1"}); key != "id:1" {
+		t.Fatalf("Expected unordered records to be keyed by ID, got %s", key)
+	}
+}
+
+func TestHandlerDispatch(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	eventDispatcher = newEventDispatcher(dispatch.PolicyDeadLetter)
+	defer func() {
+		deadLetterSink = nil
+		eventDispatcher = newEventDispatcher(dispatch.PolicySkip)
+	}()
+
+	response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", `{"eventId":"a","eventObject":"Thread","eventType":"ThreadFocused","data":{"brand":{"tenantId":"11"}}}`),
+		kinesisRecord("2", streamEvent("b", "12")),
+	}})
+	if len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+	}
+	entries := sink.Entries()
+	if len(entries) != 1 || entries[0].SequenceNumber != "1" || entries[0].Reason != deadletter.ReasonUnhandledEvent {
+		t.Fatalf("Expected record 1 to be dead-lettered as unhandled, got %+v", entries)
+	}
+}
+
+func TestHandlerIdempotency(t *testing.T) {
+	idempotencyStore = idempotency.NewMemoryStore()
+	calls := 0
+	eventDispatcher = newEventDispatcher(dispatch.PolicySkip)
+	eventDispatcher.Register(dispatch.AnyEventObject, digimodel.EventType_CaseStatusChanged, func(context.Context, *digimodel.StreamEventRequest) error {
+		calls++
+		return nil
+	})
+	defer func() { eventDispatcher = newEventDispatcher(dispatch.PolicySkip) }()
+
+	event := events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("event-1", "11")),
+		kinesisRecord("2", streamEvent("event-2", "12")),
+	}}
+	for i := 0; i < 2; i++ {
+		response, _ := handler(context.Background(), event)
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Expected each event to be handled once, got %d calls", calls)
+	}
+}
+
+func TestHandlerValidation(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	defer func() { deadLetterSink = nil }()
+
+	response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", `{"eventId":"missing-case","eventObject":"Case","eventType":"CaseStatusChanged","data":{"brand":{"tenantId":"11","businessUnitId":1}}}`),
+	}})
+	if len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+	}
+	entries := sink.Entries()
+	if len(entries) != 1 || entries[0].Reason != deadletter.ReasonValidationFailure {
+		t.Fatalf("Expected a validation failure to be dead-lettered, got %+v", entries)
+	}
+}
+
+func TestHandlerSendsToVC(t *testing.T) {
+	vcServer.Reset()
+	defer vcServer.Reset()
+	vcServer.FailNext(status.Error(codes.Unavailable, "cluster down"), status.Error(codes.InvalidArgument, "bad case"))
+
+	response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("vc-1", "11")),
+		kinesisRecord("2", streamEvent("vc-2", "12")),
+		kinesisRecord("3", streamEvent("vc-3", "13")),
+	}})
+
+	// Unavailable is retried, InvalidArgument is dead-lettered
+	if got := fmt.Sprint(failedItems(response)); got != "[1]" {
+		t.Fatalf("Expected failures [1], got %s", got)
+	}
+	sent := vcServer.Events()
+	if len(sent) != 1 || sent[0].EventId != "vc-3" || sent[0].CaseId != "case-vc-3" || sent[0].TenantId != "13" {
+		t.Fatalf("Expected only vc-3 to be accepted, got %v", sent)
+	}
+
+	// Records of different partition keys run in batch order, so vc-3 is the most recent send
+	status, ok := clusterPersisters.Status(vcfake.Target)
+	if !ok || status.Failing() || status.LastSuccessAt.IsZero() || status.LastError == "" {
+		t.Fatalf("Expected the fake cluster to record its last error and recover, got %+v", status)
+	}
+}
+
+func TestHandlerSameKeyRetry(t *testing.T) {
+	vcServer.Reset()
+	defer vcServer.Reset()
+	idempotencyStore = idempotency.NewMemoryStore()
+	vcServer.FailNext(status.Error(codes.Unavailable, "cluster down"))
+
+	open := kinesisRecord("1", strings.Replace(streamEvent("same-key-1", "11"), `"case-same-key-1"`, `"case-same-key"`, 1))
+	closed := kinesisRecord("2", strings.Replace(strings.Replace(streamEvent("same-key-2", "11"),
+		`"case-same-key-2"`, `"case-same-key"`, 1), `"status":"open"`, `"status":"closed"`, 1))
+	closed.Kinesis.PartitionKey = open.Kinesis.PartitionKey
+	event := events.KinesisEvent{Records: []events.KinesisEventRecord{open, closed}}
+
+	// The closed status must wait for the open status it follows, so both are retried
+	response, _ := handler(context.Background(), event)
+	if got := fmt.Sprint(failedItems(response)); got != "[1 2]" {
+		t.Fatalf("Expected failures [1 2], got %s", got)
+	}
+	if sent := vcServer.Events(); len(sent) != 0 {
+		t.Fatalf("Expected nothing to be accepted while the first record fails, got %v", sent)
+	}
+
+	response, _ = handler(context.Background(), event)
+	if len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no batch item failures on redelivery, got %v", failedItems(response))
+	}
+	sent := vcServer.Events()
+	if len(sent) != 2 || sent[0].Status != "open" || sent[1].Status != "closed" {
+		t.Fatalf("Expected open then closed to be accepted, got %v", sent)
+	}
+}
+
+func TestHandlerNoCluster(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	resolver := clusterResolver
+	clusterResolver = cluster.NewResolver(cluster.NewStaticSource([]cluster.Assignment{
+		{TenantID: "11", ServerInfo: cluster.ServerInfo{ClusterID: "fake", Endpoint: vcfake.Target}},
+	}, nil), time.Minute, time.Minute)
+	recordAttempts = newAttemptCounter()
+	maxRecordAttempts = 2
+	defer func() {
+		deadLetterSink = nil
+		clusterResolver = resolver
+		maxRecordAttempts = 0
+	}()
+	event := events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("cluster-1", "11")),
+		kinesisRecord("2", streamEvent("cluster-2", "99")),
+	}}
+
+	response, _ := handler(context.Background(), event)
+	if got := fmt.Sprint(failedItems(response)); got != "[2]" {
+		t.Fatalf("Expected tenant 99 to be retried on the first attempt, got %s", got)
+	}
+	if entries := sink.Entries(); len(entries) != 0 {
+		t.Fatalf("Expected no dead letter entries on the first attempt, got %+v", entries)
+	}
+
+	response, _ = handler(context.Background(), event)
+	if len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no batch item failures, got %v", failedItems(response))
+	}
+	entries := sink.Entries()
+	if len(entries) != 1 || entries[0].SequenceNumber != "2" || entries[0].Reason != deadletter.ReasonNoCluster || entries[0].Attempts != 2 {
+		t.Fatalf("Expected tenant 99 to be dead-lettered without a cluster, got %+v", entries)
+	}
+}
+
+func TestCheckClusterSource(t *testing.T) {
+	for _, name := range []string{"VcGrpcTarget", "TenantClusterMap", "ClusterMapFile", "ClusterLookupUrl"} {
+		t.Setenv(name, "")
+	}
+	if err := checkClusterSource(dispatch.NewRegistry(dispatch.PolicySkip)); err != nil {
+		t.Fatalf("Expected no error without a CaseStatusChanged handler, got %v", err)
+	}
+	if err := checkClusterSource(newEventDispatcher(dispatch.PolicySkip)); err == nil {
+		t.Fatalf("Expected an error without a cluster source")
+	}
+	t.Setenv("TenantClusterMap", "tenant-cluster-map")
+	if err := checkClusterSource(newEventDispatcher(dispatch.PolicySkip)); err != nil {
+		t.Fatalf("Expected no error with a cluster source, got %v", err)
+	}
+}
+
+func TestHandlerCircuitBreaker(t *testing.T) {
+	persisters, resolver := clusterPersisters, clusterResolver
+	clusterPersisters = persister.NewRegistry(newVCPersister, breaker.Config{FailureThreshold: 2, OpenTimeout: time.Minute})
+	// Both clusters reach the fake server, which fails every event of tenant 12
+	clusterResolver = cluster.NewResolver(cluster.NewStaticSource([]cluster.Assignment{
+		{TenantID: "11", ServerInfo: cluster.ServerInfo{ClusterID: "up", Endpoint: vcfake.Target}},
+		{TenantID: "12", ServerInfo: cluster.ServerInfo{ClusterID: "down", Endpoint: "passthrough:///vcfake-down"}},
+	}, nil), time.Minute, time.Minute)
+	vcServer.Reset()
+	var attempts atomic.Int32
+	vcServer.HandleWith(func(_ context.Context, event *vcpb.CaseStatusChangedEvent) error {
+		if event.TenantId == "12" {
+			attempts.Add(1)
+			return status.Error(codes.Unavailable, "cluster down")
+		}
+		return nil
+	})
+	defer func() {
+		clusterPersisters.Close()
+		clusterPersisters, clusterResolver = persisters, resolver
+		vcServer.Reset()
+	}()
+
+	response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("breaker-1", "12")),
+		kinesisRecord("2", streamEvent("breaker-2", "12")),
+		kinesisRecord("3", streamEvent("breaker-3", "11")),
+		kinesisRecord("4", streamEvent("breaker-4", "12")),
+		kinesisRecord("5", streamEvent("breaker-5", "12")),
+		kinesisRecord("6", streamEvent("breaker-6", "11")),
+	}})
+
+	if got := fmt.Sprint(failedItems(response)); got != "[1 2 4 5]" {
+		t.Fatalf("Expected failures [1 2 4 5], got %s", got)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("Expected the open circuit to stop sends after 2 failures, got %d", attempts.Load())
+	}
+	if len(vcServer.Events()) != 2 {
+		t.Fatalf("Expected both records of the healthy cluster to be sent, got %v", vcServer.Events())
+	}
+	failing := clusterPersisters.Failing()
+	if len(failing) != 1 || failing[0].ClusterID != "down" || failing[0].Circuit != breaker.Open {
+		t.Fatalf("Expected only the down cluster to be failing, got %+v", failing)
+	}
+}
+
+func TestHandlerCircuitBreakerRetryBudget(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	persisters, resolver := clusterPersisters, clusterResolver
+	clusterPersisters = persister.NewRegistry(newVCPersister, breaker.Config{FailureThreshold: 1, OpenTimeout: time.Minute})
+	clusterResolver = cluster.NewResolver(cluster.NewStaticSource([]cluster.Assignment{
+		{TenantID: "12", ServerInfo: cluster.ServerInfo{ClusterID: "down", Endpoint: vcfake.Target}},
+	}, nil), time.Minute, time.Minute)
+	recordAttempts = newAttemptCounter()
+	maxRecordAttempts = 2
+	vcServer.Reset()
+	var attempts atomic.Int32
+	vcServer.HandleWith(func(context.Context, *vcpb.CaseStatusChangedEvent) error {
+		attempts.Add(1)
+		return status.Error(codes.Unavailable, "cluster down")
+	})
+	defer func() {
+		deadLetterSink = nil
+		maxRecordAttempts = 0
+		clusterPersisters.Close()
+		clusterPersisters, clusterResolver = persisters, resolver
+		vcServer.Reset()
+	}()
+
+	// The first send fails and opens the circuit, every later delivery fails fast
+	event := events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("budget-1", "12")),
+		kinesisRecord("2", streamEvent("budget-2", "12")),
+	}}
+	for i := 0; i < 3; i++ {
+		response, _ := handler(context.Background(), event)
+		if got := fmt.Sprint(failedItems(response)); got != "[1 2]" {
+			t.Fatalf("Expected failures [1 2] on delivery %d, got %s", i+1, got)
+		}
+	}
+	if attempts.Load() != 1 {
+		t.Fatalf("Expected a single send before the circuit opened, got %d", attempts.Load())
+	}
+	if entries := sink.Entries(); len(entries) != 0 {
+		t.Fatalf("Expected records failing fast on an open circuit to not use their retry budget, got %+v", entries)
+	}
+}
+
+func TestHandlerRules(t *testing.T) {
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	var err error
+	recordRules, err = rules.Parse([]byte(`{
+		"tenants": {"deny": ["0"], "action": "fail"},
+		"businessUnits": {"deny": [2], "action": "deadletter"},
+		"caseStatuses": {"deny": ["open"], "action": "skip"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcServer.Reset()
+	defer func() {
+		deadLetterSink = nil
+		recordRules = rules.Default()
+		vcServer.Reset()
+	}()
+
+	otherUnit := strings.Replace(streamEvent("rules-3", "11"), `"businessUnitId":1`, `"businessUnitId":2`, 1)
+	closed := strings.Replace(streamEvent("rules-4", "11"), `"status":"open"`, `"status":"closed"`, 1)
+	response, _ := handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("rules-1", "0")),
+		kinesisRecord("2", streamEvent("rules-2", "11")),
+		kinesisRecord("3", otherUnit),
+		kinesisRecord("4", closed),
+	}})
+
+	if got := fmt.Sprint(failedItems(response)); got != "[1]" {
+		t.Fatalf("Expected failures [1], got %s", got)
+	}
+	entries := sink.Entries()
+	if len(entries) != 1 || entries[0].SequenceNumber != "3" || entries[0].Reason != deadletter.ReasonDenied {
+		t.Fatalf("Expected business unit 2 to be dead-lettered, got %+v", entries)
+	}
+	sent := vcServer.Events()
+	if len(sent) != 1 || sent[0].EventId != "rules-4" {
+		t.Fatalf("Expected only the closed case to be sent, got %v", sent)
+	}
+}
+
+func TestHandlerLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	base := logger
+	logger = zap.New(core)
+	defer func() { logger = base }()
+
+	record := kinesisRecord("1", streamEvent("log-1", "11"))
+	record.EventID = "shardId-000000000002:1"
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "request-1"})
+	handler(ctx, events.KinesisEvent{Records: []events.KinesisEventRecord{record}})
+
+	processing := logs.FilterMessage("Processing event").All()
+	if len(processing) != 1 {
+		t.Fatalf("Expected 1 processing line, got %d", len(processing))
+	}
+	fields := processing[0].ContextMap()
+	for key, want := range map[string]string{
+		"requestId":                      "request-1",
+		"shardId":                        "shardId-000000000002",
+		"sequenceNumber":                 "1",
+		"partitionKey":                   "partition-1",
+		"StreamEventRequest.EventID":     "log-1",
+		"StreamEventRequest.TenantID":    "11",
+		"StreamEventRequest.EventType":   "CaseStatusChanged",
+		"StreamEventRequest.EventObject": "Case",
+	} {
+		if fields[key] != want {
+			t.Fatalf("Expected %s to be %q, got %v", key, want, fields[key])
+		}
+	}
+}
+
+func TestHandlerRedactsLogs(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	base := logger
+	logger = zap.New(core)
+	defer func() {
+		logger = base
+		logRawRecords = false
+	}()
+	data := strings.Replace(streamEvent("pii-1", "11"), `"brand":`, `"user":{"emailAddress":"agent@example.com"},"brand":`, 1)
+
+	handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{kinesisRecord("1", data)}})
+	if logs.FilterMessage("Received record").Len() != 0 {
+		t.Fatal("Expected raw records not to be logged by default")
+	}
+
+	logRawRecords = true
+	handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{kinesisRecord("1", data)}})
+	raw := logs.FilterMessage("Received record").All()
+	if len(raw) != 1 {
+		t.Fatalf("Expected 1 raw record line, got %d", len(raw))
+	}
+	for _, entry := range logs.All() {
+		enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+		line, _ := enc.EncodeEntry(entry.Entry, entry.Context)
+		if strings.Contains(line.String(), "agent@example.com") {
+			t.Fatalf("Expected the email address to be redacted, got %s", line.String())
+		}
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	t.Run("Debug logging", func(t *testing.T) {
+		t.Setenv("DebugLogging", "true")
+		l, err := newLogger()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !l.Core().Enabled(zapcore.DebugLevel) {
+			t.Fatal("Expected debug lines to be enabled")
+		}
+	})
+
+	t.Run("LogLevel wins over DebugLogging", func(t *testing.T) {
+		t.Setenv("DebugLogging", "true")
+		t.Setenv("LogLevel", "WARN")
+		t.Setenv("LogEncoding", "console")
+		l, err := newLogger()
 		if err != nil {
-			t.Fatal("Everything should be ok")
+			t.Fatal(err)
+		}
+		if l.Core().Enabled(zapcore.InfoLevel) || !l.Core().Enabled(zapcore.WarnLevel) {
+			t.Fatal("Expected only warn and above to be enabled")
+		}
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		t.Setenv("LogLevel", "loud")
+		if _, err := newLogger(); err == nil {
+			t.Fatal("Expected an error for an invalid LogLevel")
+		}
+		t.Setenv("LogLevel", "")
+		t.Setenv("LogEncoding", "xml")
+		if _, err := newLogger(); err == nil {
+			t.Fatal("Expected an error for an invalid LogEncoding")
+		}
+	})
+}
+
+func TestHandlerMetrics(t *testing.T) {
+	metricsSink.Reset()
+	sink := deadletter.NewMemorySink()
+	deadLetterSink = sink
+	defer func() { deadLetterSink = nil }()
+	unregistered := strings.Replace(streamEvent("metrics-4", "11"), `"eventType":"CaseStatusChanged"`, `"eventType":"CaseCreated"`, 1)
+
+	handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("metrics-1", "11")),
+		kinesisRecord("2", streamEvent("metrics-2", "0")),
+		kinesisRecord("3", "not json"),
+		kinesisRecord("4", unregistered),
+	}})
+
+	caseStatus := metrics.Dimensions{metrics.DimensionEventObject: "Case", metrics.DimensionEventType: "CaseStatusChanged"}
+	unknown := metrics.Dimensions{metrics.DimensionEventObject: "Unknown", metrics.DimensionEventType: "Unknown"}
+	for _, test := range []struct {
+		name string
+		dims metrics.Dimensions
+		want float64
+	}{
+		{metrics.RecordsReceived, metrics.Dimensions{}, 4},
+		{metrics.RecordsReceived, caseStatus, 2},
+		{metrics.RecordsSucceeded, caseStatus, 1},
+		{metrics.RecordsFailed, metrics.Dimensions{metrics.DimensionReason: "Denied"}, 1},
+		{metrics.RecordsDeadLettered, metrics.Dimensions{metrics.DimensionReason: string(deadletter.ReasonDecodeFailure)}, 1},
+		{metrics.RecordsDeadLettered, unknown, 1},
+		{metrics.RecordsSkipped, metrics.Dimensions{metrics.DimensionEventObject: "Case", metrics.DimensionEventType: "CaseCreated"}, 1},
+	} {
+		if got := metricsSink.Sum(test.name, test.dims); got != test.want {
+			t.Fatalf("Expected %s %v to be %v, got %v", test.name, test.dims, test.want, got)
+		}
+	}
+	if got := len(metricsSink.Values(metrics.RecordLatency, metrics.Dimensions{})); got != 4 {
+		t.Fatalf("Expected 4 latencies, got %d", got)
+	}
+
+	t.Run("Unprocessed records", func(t *testing.T) {
+		metricsSink.Reset()
+		ctx, cancel := context.WithTimeout(context.Background(), recordProcessor.SafetyMargin/2)
+		defer cancel()
+		handler(ctx, events.KinesisEvent{Records: []events.KinesisEventRecord{kinesisRecord("1", streamEvent("metrics-5", "11"))}})
+		if got := metricsSink.Sum(metrics.RecordsFailed, metrics.Dimensions{metrics.DimensionReason: "Unprocessed"}); got != 1 {
+			t.Fatalf("Expected 1 unprocessed record, got %v", got)
+		}
+	})
+}
+
+// createdStreamEvent is a stream event created by DFO at created, arriving on the stream at arrived
+func createdStreamEvent(sequenceNumber string, eventID string, created time.Time, arrived time.Time) events.KinesisEventRecord {
+	data := strings.Replace(streamEvent(eventID, "11"), `{"eventId"`, `{"createdAt":"`+created.UTC().Format(time.RFC3339)+`","eventId"`, 1)
+	record := kinesisRecord(sequenceNumber, data)
+	record.Kinesis.ApproximateArrivalTimestamp = events.SecondsEpochTime{Time: arrived}
+	return record
+}
+
+func TestHandlerLag(t *testing.T) {
+	metricsSink.Reset()
+	now := time.Now().Truncate(time.Second)
+
+	handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		createdStreamEvent("1", "lag-1", now.Add(-3*time.Second), now.Add(-time.Second)),
+		createdStreamEvent("2", "lag-2", now.Add(-5*time.Second), now.Add(-time.Second)),
+	}})
+
+	producerLags := metricsSink.Values(metrics.ProducerToStreamLag, metrics.Dimensions{})
+	if len(producerLags) != 2 || producerLags[0]+producerLags[1] != 6000 {
+		t.Fatalf("Expected producer lags of 2s and 4s, got %v", producerLags)
+	}
+	for _, value := range metricsSink.Values(metrics.StreamToProcessedLag, metrics.Dimensions{}) {
+		if value < 1000 {
+			t.Fatalf("Expected stream lag of at least 1s, got %vms", value)
+		}
+	}
+
+	staleThreshold = time.Hour
+	defer func() { staleThreshold, stalePolicy = 0, lag.PolicyProcess }()
+	// Event IDs differ per policy so completed events are not deduplicated
+	stale := func(policy string) events.KinesisEvent {
+		return events.KinesisEvent{Records: []events.KinesisEventRecord{
+			createdStreamEvent("1", "stale-"+policy, now.Add(-2*time.Hour), now.Add(-2*time.Hour)),
+			createdStreamEvent("2", "fresh-"+policy, now, now),
+		}}
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		metricsSink.Reset()
+		stalePolicy = lag.PolicySkip
+		response, _ := handler(context.Background(), stale("skip"))
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no failures, got %v", failedItems(response))
+		}
+		if got := metricsSink.Sum(metrics.RecordsSkipped, metrics.Dimensions{}); got != 1 {
+			t.Fatalf("Expected 1 skipped record, got %v", got)
 		}
 	})
+
+	t.Run("Dead-letter", func(t *testing.T) {
+		sink := deadletter.NewMemorySink()
+		deadLetterSink = sink
+		defer func() { deadLetterSink = nil }()
+		stalePolicy = lag.PolicyDeadLetter
+		response, _ := handler(context.Background(), stale("deadletter"))
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("Expected no failures, got %v", failedItems(response))
+		}
+		entries := sink.Entries()
+		if len(entries) != 1 || entries[0].Reason != deadletter.ReasonStale || entries[0].SequenceNumber != "1" {
+			t.Fatalf("Expected the stale record to be dead-lettered, got %+v", entries)
+		}
+	})
+
+	t.Run("Process", func(t *testing.T) {
+		metricsSink.Reset()
+		stalePolicy = lag.PolicyProcess
+		handler(context.Background(), stale("process"))
+		if got := metricsSink.Sum(metrics.RecordsSucceeded, metrics.Dimensions{}); got != 2 {
+			t.Fatalf("Expected 2 succeeded records, got %v", got)
+		}
+	})
+}
+
+func TestHandlerTracing(t *testing.T) {
+	spans.Reset()
+	vcServer.Reset()
+	defer vcServer.Reset()
+
+	handler(context.Background(), events.KinesisEvent{Records: []events.KinesisEventRecord{
+		kinesisRecord("1", streamEvent("trace-1", "11")),
+	}})
+
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range spans.GetSpans() {
+		byName[span.Name] = span
+	}
+	invocation, record := byName["handler"], byName["processRecord"]
+	resolve, send := byName["cluster.Resolve"], byName["persister.Send"]
+	call := byName["/vc.casestatus.v1.CaseStatusService/SendCaseStatusChangedEvent"]
+
+	for _, link := range []struct {
+		child, parent tracetest.SpanStub
+	}{
+		{record, invocation},
+		{resolve, record},
+		{send, record},
+		{call, send},
+	} {
+		if !link.child.SpanContext.IsValid() || link.child.Parent.SpanID() != link.parent.SpanContext.SpanID() {
+			t.Fatalf("Expected %q to be a child of %q, got %+v", link.child.Name, link.parent.Name, spans.GetSpans())
+		}
+	}
+
+	attributes := map[string]string{}
+	for _, kv := range record.Attributes {
+		attributes[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attributes["event.id"] != "trace-1" || attributes["tenant.id"] != "11" || attributes["event.type"] != "CaseStatusChanged" ||
+		attributes["record.outcome"] != "succeeded" {
+		t.Fatalf("Unexpected record span attributes %v", attributes)
+	}
+
+	md := vcServer.Metadata()
+	if len(md) != 1 || len(md[0].Get("traceparent")) != 1 ||
+		!strings.Contains(md[0].Get("traceparent")[0], invocation.SpanContext.TraceID().String()) {
+		t.Fatalf("Expected the trace context to reach the VC, got %v", md)
+	}
 }
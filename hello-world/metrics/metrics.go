@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric names emitted for every invocation
+const (
+	RecordsReceived     = "RecordsReceived"
+	RecordsSucceeded    = "RecordsSucceeded"
+	RecordsFailed       = "RecordsFailed"
+	RecordsSkipped      = "RecordsSkipped"
+	RecordsDeadLettered = "RecordsDeadLettered"
+	RecordLatency       = "RecordLatency"
+	// ProducerToStreamLag is the time from DFO creating an event to it reaching the stream
+	ProducerToStreamLag = "ProducerToStreamLag"
+	// StreamToProcessedLag is the time from an event reaching the stream to its record being processed
+	StreamToProcessedLag = "StreamToProcessedLag"
+	// CompressionRatio is the decompressed size of a compressed record divided by its size on the stream
+	CompressionRatio = "CompressionRatio"
+)
+
+// Dimension names a record can be broken down by
+const (
+	DimensionEventObject = "EventObject"
+	DimensionEventType   = "EventType"
+	DimensionReason      = "Reason"
+)
+
+// Units of the metrics
+const (
+	UnitCount        = "Count"
+	UnitMilliseconds = "Milliseconds"
+	UnitNone         = "None"
+)
+
+// maxValues is the most values CloudWatch accepts for a single metric in one document
+const maxValues = 100
+
+// DefaultNamespace is the CloudWatch namespace metrics are emitted under
+const DefaultNamespace = "DigiCaseState"
+
+// DefaultDimensionSets break records down by event, by failure reason and in total
+func DefaultDimensionSets() [][]string {
+	return [][]string{{DimensionEventObject, DimensionEventType}, {DimensionReason}, {}}
+}
+
+// ParseDimensionSets reads dimension sets such as "EventObject,EventType;Reason;".
+// Sets are separated by semicolons and dimensions by commas, an empty set aggregates every record.
+// An empty string returns the DefaultDimensionSets.
+func ParseDimensionSets(s string) ([][]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultDimensionSets(), nil
+	}
+	var sets [][]string
+	for _, entry := range strings.Split(s, ";") {
+		set := []string{}
+		for _, name := range strings.Split(entry, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case "":
+			case DimensionEventObject, DimensionEventType, DimensionReason:
+				set = append(set, name)
+			default:
+				return nil, fmt.Errorf("invalid metric dimension %q", name)
+			}
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// Dimensions are the dimension values of an observation, keyed by dimension name
+type Dimensions map[string]string
+
+type observation struct {
+	name   string
+	unit   string
+	dims   Dimensions
+	values []float64
+}
+
+// Recorder collects the metrics of a single invocation and emits them together with Flush.
+// An observation is aggregated into every dimension set whose dimensions it has values for.
+// A Recorder is safe for concurrent use.
+type Recorder struct {
+	namespace     string
+	dimensionSets [][]string
+
+	mu           sync.Mutex
+	observations []observation
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder(namespace string, dimensionSets [][]string) *Recorder {
+	return &Recorder{namespace: namespace, dimensionSets: dimensionSets}
+}
+
+// Count adds n to the count metric name
+func (r *Recorder) Count(name string, dims Dimensions, n float64) {
+	r.add(observation{name: name, unit: UnitCount, dims: dims, values: []float64{n}})
+}
+
+// Duration records d as a value of the latency metric name
+func (r *Recorder) Duration(name string, dims Dimensions, d time.Duration) {
+	r.add(observation{name: name, unit: UnitMilliseconds, dims: dims, values: []float64{float64(d) / float64(time.Millisecond)}})
+}
+
+// Value records v as a value of the unitless metric name
+func (r *Recorder) Value(name string, dims Dimensions, v float64) {
+	r.add(observation{name: name, unit: UnitNone, dims: dims, values: []float64{v}})
+}
+
+func (r *Recorder) add(o observation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, o)
+}
+
+type metricDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type directive struct {
+	Namespace  string             `json:"Namespace"`
+	Dimensions [][]string         `json:"Dimensions"`
+	Metrics    []metricDefinition `json:"Metrics"`
+}
+
+type metadata struct {
+	Timestamp         int64       `json:"Timestamp"`
+	CloudWatchMetrics []directive `json:"CloudWatchMetrics"`
+}
+
+// group is the aggregate of every observation sharing a dimension set and its values
+type group struct {
+	set    []string
+	values []string
+	counts map[string]float64
+	series map[string][]float64
+	order  []metricDefinition
+}
+
+// Flush renders the collected metrics as Embedded Metric Format documents, sends them to sink and resets the Recorder.
+// Counts are summed and latencies and other values are sent as value arrays.
+func (r *Recorder) Flush(sink Sink, timestamp time.Time) error {
+	r.mu.Lock()
+	observations := r.observations
+	r.observations = nil
+	r.mu.Unlock()
+
+	var groups []*group
+	byKey := map[string]*group{}
+	for _, o := range observations {
+		for _, set := range r.dimensionSets {
+			values, ok := project(o.dims, set)
+			if !ok {
+				continue
+			}
+			key := strings.Join(set, ",") + "\x00" + strings.Join(values, "\x00")
+			g, ok := byKey[key]
+			if !ok {
+				g = &group{set: set, values: values, counts: map[string]float64{}, series: map[string][]float64{}}
+				byKey[key] = g
+				groups = append(groups, g)
+			}
+			if _, seen := g.counts[o.name]; !seen && g.series[o.name] == nil {
+				g.order = append(g.order, metricDefinition{Name: o.name, Unit: o.unit})
+			}
+			if o.unit == UnitCount {
+				g.counts[o.name] += o.values[0]
+			} else {
+				g.series[o.name] = append(g.series[o.name], o.values...)
+			}
+		}
+	}
+
+	for _, g := range groups {
+		for _, document := range r.documents(g, timestamp) {
+			if err := sink.Emit(document); err != nil {
+				return fmt.Errorf("failed to emit metrics: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// project returns the values of dims for set, or false when dims lacks one of them
+func project(dims Dimensions, set []string) ([]string, bool) {
+	values := make([]string, len(set))
+	for i, name := range set {
+		value, ok := dims[name]
+		if !ok {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// documents renders g, splitting latency values over several documents when there are too many for one
+func (r *Recorder) documents(g *group, timestamp time.Time) [][]byte {
+	var documents [][]byte
+	for chunk := 0; ; chunk++ {
+		fields := map[string]interface{}{}
+		for i, name := range g.set {
+			fields[name] = g.values[i]
+		}
+		var definitions []metricDefinition
+		for _, definition := range g.order {
+			if definition.Unit == UnitCount {
+				if chunk == 0 {
+					fields[definition.Name] = g.counts[definition.Name]
+					definitions = append(definitions, definition)
+				}
+				continue
+			}
+			values := g.series[definition.Name]
+			if chunk*maxValues >= len(values) {
+				continue
+			}
+			values = values[chunk*maxValues : min((chunk+1)*maxValues, len(values))]
+			fields[definition.Name] = values
+			definitions = append(definitions, definition)
+		}
+		if len(definitions) == 0 {
+			return documents
+		}
+		fields["_aws"] = metadata{
+			Timestamp: timestamp.UnixMilli(),
+			CloudWatchMetrics: []directive{{
+				Namespace:  r.namespace,
+				Dimensions: [][]string{g.set},
+				Metrics:    definitions,
+			}},
+		}
+		// Maps of plain values always marshal
+		document, _ := json.Marshal(fields)
+		documents = append(documents, document)
+	}
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder("Test", DefaultDimensionSets())
+	caseStatus := Dimensions{DimensionEventObject: "Case", DimensionEventType: "CaseStatusChanged"}
+	failed := Dimensions{DimensionEventObject: "Case", DimensionEventType: "CaseStatusChanged", DimensionReason: "Deadline"}
+
+	recorder.Count(RecordsReceived, caseStatus, 1)
+	recorder.Count(RecordsReceived, caseStatus, 1)
+	recorder.Count(RecordsReceived, Dimensions{DimensionEventObject: "Unknown", DimensionEventType: "Unknown"}, 1)
+	recorder.Count(RecordsFailed, failed, 1)
+	recorder.Duration(RecordLatency, caseStatus, 1500*time.Microsecond)
+	recorder.Duration(RecordLatency, caseStatus, 2*time.Millisecond)
+	recorder.Value(CompressionRatio, caseStatus, 4.5)
+
+	sink := NewMemorySink()
+	if err := recorder.Flush(sink, time.UnixMilli(1714557600000)); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Counts are summed per dimension set", func(t *testing.T) {
+		if got := sink.Sum(RecordsReceived, caseStatus); got != 2 {
+			t.Fatalf("Expected 2 records received for case status changes, got %v", got)
+		}
+		if got := sink.Sum(RecordsReceived, Dimensions{}); got != 3 {
+			t.Fatalf("Expected 3 records received in total, got %v", got)
+		}
+		if got := sink.Sum(RecordsFailed, Dimensions{DimensionReason: "Deadline"}); got != 1 {
+			t.Fatalf("Expected 1 failure by reason, got %v", got)
+		}
+		if got := sink.Sum(RecordsReceived, Dimensions{DimensionReason: ""}); got != 0 {
+			t.Fatalf("Expected observations without a reason to be left out of the reason set, got %v", got)
+		}
+	})
+
+	t.Run("Latencies are sent as values", func(t *testing.T) {
+		values := sink.Values(RecordLatency, caseStatus)
+		if len(values) != 2 || values[0] != 1.5 || values[1] != 2 {
+			t.Fatalf("Expected latencies [1.5 2], got %v", values)
+		}
+	})
+
+	t.Run("Values are sent without a unit", func(t *testing.T) {
+		values := sink.Values(CompressionRatio, caseStatus)
+		if len(values) != 1 || values[0] != 4.5 {
+			t.Fatalf("Expected ratios [4.5], got %v", values)
+		}
+	})
+
+	t.Run("Documents follow the embedded metric format", func(t *testing.T) {
+		d := sink.Documents()[0]
+		aws := d["_aws"].(map[string]interface{})
+		if aws["Timestamp"].(float64) != 1714557600000 {
+			t.Fatalf("Unexpected timestamp %v", aws["Timestamp"])
+		}
+		directive := d.directive()
+		if directive["Namespace"] != "Test" {
+			t.Fatalf("Unexpected namespace %v", directive["Namespace"])
+		}
+		if len(directive["Metrics"].([]interface{})) != 4 {
+			t.Fatalf("Expected 4 metric definitions, got %v", directive["Metrics"])
+		}
+	})
+
+	t.Run("Flush resets the recorder", func(t *testing.T) {
+		sink.Reset()
+		recorder.Flush(sink, time.Now())
+		if len(sink.Documents()) != 0 {
+			t.Fatalf("Expected no documents, got %v", sink.Documents())
+		}
+	})
+}
+
+func TestRecorderSplitsLargeSeries(t *testing.T) {
+	recorder := NewRecorder("Test", [][]string{{}})
+	for i := 0; i < 250; i++ {
+		recorder.Duration(RecordLatency, Dimensions{}, time.Millisecond)
+	}
+	recorder.Count(RecordsReceived, Dimensions{}, 250)
+
+	sink := NewMemorySink()
+	recorder.Flush(sink, time.Now())
+	if len(sink.Documents()) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(sink.Documents()))
+	}
+	if got := len(sink.Values(RecordLatency, Dimensions{})); got != 250 {
+		t.Fatalf("Expected 250 latencies, got %d", got)
+	}
+	if got := sink.Sum(RecordsReceived, Dimensions{}); got != 250 {
+		t.Fatalf("Expected the count to be sent once, got %v", got)
+	}
+}
+
+func TestParseDimensionSets(t *testing.T) {
+	sets, err := ParseDimensionSets("EventObject, EventType;Reason;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(sets); string(got) != `[["EventObject","EventType"],["Reason"],[]]` {
+		t.Fatalf("Unexpected dimension sets %s", got)
+	}
+	if _, err := ParseDimensionSets("Tenant"); err == nil {
+		t.Fatal("Expected an error for an unknown dimension")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder("Test", [][]string{{}})
+	recorder.Count(RecordsReceived, Dimensions{}, 1)
+	if err := recorder.Flush(NewWriterSink(&buf), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("}\n")) || bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Fatalf("Expected a single line, got %q", buf.String())
+	}
+
+	recorder.Count(RecordsReceived, Dimensions{}, 1)
+	if err := recorder.Flush(NewWriterSink(failingWriter{}), time.Now()); err == nil {
+		t.Fatal("Expected the write error")
+	}
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Sink receives Embedded Metric Format documents
+type Sink interface {
+	Emit(document []byte) error
+}
+
+// WriterSink writes every document on its own line, which is how Lambda picks up EMF from stdout
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a WriterSink writing to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Emit implements Sink
+func (s *WriterSink) Emit(document []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(append(document, '\n'))
+	return err
+}
+
+// Document is an emitted Embedded Metric Format document, decoded for assertions
+type Document map[string]interface{}
+
+// Dimensions returns the dimension values of the document
+func (d Document) Dimensions() Dimensions {
+	dims := Dimensions{}
+	for _, set := range d.directive()["Dimensions"].([]interface{}) {
+		for _, name := range set.([]interface{}) {
+			dims[name.(string)], _ = d[name.(string)].(string)
+		}
+	}
+	return dims
+}
+
+func (d Document) directive() map[string]interface{} {
+	aws := d["_aws"].(map[string]interface{})
+	return aws["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+}
+
+// MemorySink keeps emitted documents in memory and is intended for tests
+type MemorySink struct {
+	mu        sync.Mutex
+	documents []Document
+}
+
+// NewMemorySink returns an empty MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Emit implements Sink
+func (s *MemorySink) Emit(document []byte) error {
+	var d Document
+	if err := json.Unmarshal(document, &d); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents = append(s.documents, d)
+	return nil
+}
+
+// Documents returns a copy of every document emitted so far
+func (s *MemorySink) Documents() []Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Document(nil), s.documents...)
+}
+
+// Reset forgets every emitted document
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents = nil
+}
+
+// Sum adds up the count metric name over every document whose dimension values equal dims
+func (s *MemorySink) Sum(name string, dims Dimensions) float64 {
+	var sum float64
+	for _, d := range s.matching(dims) {
+		if value, ok := d[name].(float64); ok {
+			sum += value
+		}
+	}
+	return sum
+}
+
+// Values returns every value of the latency metric name in documents whose dimension values equal dims
+func (s *MemorySink) Values(name string, dims Dimensions) []float64 {
+	var values []float64
+	for _, d := range s.matching(dims) {
+		if series, ok := d[name].([]interface{}); ok {
+			for _, value := range series {
+				values = append(values, value.(float64))
+			}
+		}
+	}
+	return values
+}
+
+func (s *MemorySink) matching(dims Dimensions) []Document {
+	var matching []Document
+	for _, d := range s.Documents() {
+		got := d.Dimensions()
+		if len(got) != len(dims) {
+			continue
+		}
+		equal := true
+		for key, value := range dims {
+			if got[key] != value {
+				equal = false
+			}
+		}
+		if equal {
+			matching = append(matching, d)
+		}
+	}
+	return matching
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"hello-world/source"
+)
+
+// partitionKey orders records by the key their source keeps in order, a Kinesis partition key or SQS message group ID.
+// Records from a source without ordering, such as a standard SQS queue, are each keyed on their own.
+func partitionKey(record source.Record) string {
+	if record.Key == "" {
+		return "id:" + record.ID
+	}
+	return record.Key
+}
+
+// caseID orders records by the case they belong to, so that unrelated cases sharing a partition key
+// can be processed at the same time. Records without a case ID fall back to their partition key.
+func caseID(record source.Record) string {
+	var event struct {
+		Data struct {
+			Case    struct{ ID string } `json:"case"`
+			Contact struct{ ID string } `json:"contact"`
+		} `json:"data"`
+	}
+	// Invalid records fail in processRecord, here they only need a key
+	_ = json.Unmarshal(record.Data, &event)
+
+	switch {
+	case event.Data.Case.ID != "":
+		return "case:" + event.Data.Case.ID
+	case event.Data.Contact.ID != "":
+		return "case:" + event.Data.Contact.ID
+	default:
+		return "partition:" + partitionKey(record)
+	}
+}
+
+// orderingKeyFromString selects the ordering key named by the RecordOrderingKey environment variable
+func orderingKeyFromString(s string) (func(source.Record) string, error) {
+	switch strings.TrimSpace(strings.ToLower(s)) {
+	case "", "partitionkey":
+		return partitionKey, nil
+	case "caseid":
+		return caseID, nil
+	default:
+		return nil, fmt.Errorf("invalid RecordOrderingKey %q", s)
+	}
+}
+
+// loadConcurrency reads RecordConcurrency from the environment
+func loadConcurrency() (int, error) {
+	value := os.Getenv("RecordConcurrency")
+	if value == "" {
+		return 1, nil
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		return 0, fmt.Errorf("invalid RecordConcurrency %q", value)
+	}
+	return concurrency, nil
+}
@@ -0,0 +1,233 @@
+package persister
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"hello-world/batch"
+	"hello-world/breaker"
+	"hello-world/cluster"
+	"hello-world/digimodel"
+	"hello-world/tracing"
+)
+
+const tracerName = "hello-world/persister"
+
+// Persister sends case status updates to a single VC cluster.
+// Implementations must be safe for concurrent use.
+type Persister interface {
+	SendCaseStatusChanged(ctx context.Context, update *digimodel.DigiCaseStatusUpdate) error
+	Close() error
+}
+
+// Factory creates the Persister for a target cluster
+type Factory func(target cluster.ServerInfo) (Persister, error)
+
+// TargetStatus is the most recent outcome of sending to a target cluster
+type TargetStatus struct {
+	ClusterID string `json:"clusterId"`
+	Endpoint  string `json:"endpoint"`
+	// LastSuccessAt is zero until the first send succeeds
+	LastSuccessAt time.Time `json:"lastSuccessAt,omitempty"`
+	// LastError and LastErrorAt keep the most recent error even after later sends succeed
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt,omitempty"`
+	// ConsecutiveFailures is reset by every successful send
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// Circuit is the state of the circuit breaker of the target
+	Circuit breaker.State `json:"circuit"`
+}
+
+// Failing reports whether the most recent send to the target failed
+func (s TargetStatus) Failing() bool {
+	return s.ConsecutiveFailures > 0
+}
+
+type target struct {
+	persister Persister
+	breaker   *breaker.Breaker
+	status    TargetStatus
+}
+
+// snapshot returns the status of t along with the current circuit state
+func (t *target) snapshot() TargetStatus {
+	status := t.status
+	status.Circuit = t.breaker.State()
+	return status
+}
+
+// Registry lazily creates one Persister per target cluster and tracks the status of each target.
+// A Registry kept in a package variable survives warm invocations, so connections are reused.
+// Every target has its own circuit breaker, so a cluster that is down fails fast without slowing down the others.
+type Registry struct {
+	factory Factory
+	breaker breaker.Config
+	now     func() time.Time
+
+	mu      sync.Mutex
+	targets map[string]*target
+}
+
+// NewRegistry returns an empty Registry that creates Persisters with factory
+// and guards each target with a circuit breaker using config
+func NewRegistry(factory Factory, config breaker.Config) *Registry {
+	return &Registry{
+		factory: factory,
+		breaker: config,
+		now:     time.Now,
+		targets: map[string]*target{},
+	}
+}
+
+// GetOrCreateClusterPersisterForTarget returns the Persister for info, creating it on first use.
+// Targets are keyed by endpoint, so a cluster that moves to a new endpoint gets a new Persister.
+func (r *Registry) GetOrCreateClusterPersisterForTarget(info cluster.ServerInfo) (Persister, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.targets[info.Endpoint]; ok && t.persister != nil {
+		return t.persister, nil
+	}
+
+	p, err := r.factory(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create persister for cluster %s: %w", info.ClusterID, err)
+	}
+	r.targetFor(info).persister = p
+	return p, nil
+}
+
+// targetFor returns the target of info, adding it without a Persister when it is unknown.
+// The caller must hold r.mu.
+func (r *Registry) targetFor(info cluster.ServerInfo) *target {
+	t, ok := r.targets[info.Endpoint]
+	if !ok {
+		t = &target{
+			breaker: breaker.New(r.breaker),
+			status:  TargetStatus{ClusterID: info.ClusterID, Endpoint: info.Endpoint},
+		}
+		r.targets[info.Endpoint] = t
+	}
+	return t
+}
+
+// breakerFor returns the circuit breaker of info
+func (r *Registry) breakerFor(info cluster.ServerInfo) *breaker.Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.targetFor(info).breaker
+}
+
+// UpdatePersisterTargetStatus records the outcome of a send to info.
+// A nil err records a success, anything else is stored as the most recent error.
+func (r *Registry) UpdatePersisterTargetStatus(info cluster.ServerInfo, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.targetFor(info)
+	now := r.now()
+	if err == nil {
+		t.status.LastSuccessAt = now
+		t.status.ConsecutiveFailures = 0
+		return
+	}
+	t.status.LastError = err.Error()
+	t.status.LastErrorAt = now
+	t.status.ConsecutiveFailures++
+}
+
+// Send sends update to info through its Persister and records the outcome.
+// While the circuit of info is open the update fails fast with a retryable error wrapping breaker.ErrOpen.
+func (r *Registry) Send(ctx context.Context, info cluster.ServerInfo, update *digimodel.DigiCaseStatusUpdate) (err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "persister.Send", trace.WithAttributes(
+		tracing.AttributeEventID.String(update.EventID),
+		tracing.AttributeTenantID.String(update.TenantID),
+		attribute.String("cluster.id", info.ClusterID),
+		attribute.String("server.address", info.Endpoint),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	b := r.breakerFor(info)
+	span.SetAttributes(attribute.String("circuit.state", b.State().String()))
+	if err := b.Allow(); err != nil {
+		return batch.Retryable(fmt.Errorf("cluster %s at %s: %w", info.ClusterID, info.Endpoint, err))
+	}
+
+	p, err := r.GetOrCreateClusterPersisterForTarget(info)
+	if err == nil {
+		err = p.SendCaseStatusChanged(ctx, update)
+	}
+
+	switch {
+	case err == nil || batch.IsPermanent(err):
+		// The cluster answered, a permanent failure is a problem with the update
+		b.Success()
+	case ctx.Err() != nil:
+		// The invocation ran out of time, which says nothing about the cluster
+		b.Ignore()
+	default:
+		b.Failure()
+	}
+	r.UpdatePersisterTargetStatus(info, err)
+	return err
+}
+
+// Status returns the status of the target at endpoint
+func (r *Registry) Status(endpoint string) (TargetStatus, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.targets[endpoint]
+	if !ok {
+		return TargetStatus{}, false
+	}
+	return t.snapshot(), true
+}
+
+// Statuses returns the status of every known target ordered by cluster ID and endpoint
+func (r *Registry) Statuses() []TargetStatus {
+	r.mu.Lock()
+	statuses := make([]TargetStatus, 0, len(r.targets))
+	for _, t := range r.targets {
+		statuses = append(statuses, t.snapshot())
+	}
+	r.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].ClusterID != statuses[j].ClusterID {
+			return statuses[i].ClusterID < statuses[j].ClusterID
+		}
+		return statuses[i].Endpoint < statuses[j].Endpoint
+	})
+	return statuses
+}
+
+// Failing returns the status of every target whose most recent send failed or whose circuit is not closed
+func (r *Registry) Failing() []TargetStatus {
+	var failing []TargetStatus
+	for _, status := range r.Statuses() {
+		if status.Failing() || status.Circuit != breaker.Closed {
+			failing = append(failing, status)
+		}
+	}
+	return failing
+}
+
+// Close closes every Persister and forgets all targets
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var firstErr error
+	for endpoint, t := range r.targets {
+		if t.persister != nil {
+			if err := t.persister.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		delete(r.targets, endpoint)
+	}
+	return firstErr
+}
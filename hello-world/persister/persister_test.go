@@ -0,0 +1,138 @@
+package persister
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"hello-world/batch"
+	"hello-world/breaker"
+	"hello-world/cluster"
+	"hello-world/digimodel"
+)
+
+type fakePersister struct {
+	err    error
+	sent   int
+	closed bool
+}
+
+func (p *fakePersister) SendCaseStatusChanged(context.Context, *digimodel.DigiCaseStatusUpdate) error {
+	p.sent++
+	return p.err
+}
+
+func (p *fakePersister) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	created := map[string]*fakePersister{}
+	registry := NewRegistry(func(target cluster.ServerInfo) (Persister, error) {
+		if target.Endpoint == "" {
+			return nil, errors.New("missing endpoint")
+		}
+		p := &fakePersister{}
+		created[target.Endpoint] = p
+		return p, nil
+	}, breaker.Config{FailureThreshold: 3})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+
+	c1 := cluster.ServerInfo{ClusterID: "c1", Endpoint: "c1:9884"}
+	c2 := cluster.ServerInfo{ClusterID: "c2", Endpoint: "c2:9884"}
+	update := &digimodel.DigiCaseStatusUpdate{EventID: "1"}
+
+	t.Run("Persisters are created once per target", func(t *testing.T) {
+		first, _ := registry.GetOrCreateClusterPersisterForTarget(c1)
+		second, _ := registry.GetOrCreateClusterPersisterForTarget(c1)
+		if first != second || len(created) != 1 {
+			t.Fatalf("Expected one persister for c1, got %d", len(created))
+		}
+	})
+
+	t.Run("Status tracks the most recent outcome", func(t *testing.T) {
+		if err := registry.Send(context.Background(), c1, update); err != nil {
+			t.Fatal(err)
+		}
+		registry.GetOrCreateClusterPersisterForTarget(c2)
+		created["c2:9884"].err = errors.New("unavailable")
+		now = now.Add(time.Second)
+		registry.Send(context.Background(), c2, update)
+		registry.Send(context.Background(), c2, update)
+
+		failing := registry.Failing()
+		if len(failing) != 1 || failing[0].ClusterID != "c2" || failing[0].ConsecutiveFailures != 2 ||
+			failing[0].LastError != "unavailable" || !failing[0].LastErrorAt.Equal(now) {
+			t.Fatalf("Expected c2 to be failing, got %+v", failing)
+		}
+		if status, _ := registry.Status("c1:9884"); status.Failing() || status.LastSuccessAt.IsZero() {
+			t.Fatalf("Expected c1 to be healthy, got %+v", status)
+		}
+
+		created["c2:9884"].err = nil
+		registry.Send(context.Background(), c2, update)
+		status, _ := registry.Status("c2:9884")
+		if status.Failing() || status.LastError != "unavailable" {
+			t.Fatalf("Expected c2 to recover and keep its last error, got %+v", status)
+		}
+	})
+
+	t.Run("Factory errors are recorded", func(t *testing.T) {
+		broken := cluster.ServerInfo{ClusterID: "broken"}
+		if err := registry.Send(context.Background(), broken, update); err == nil {
+			t.Fatal("Expected an error for a target without an endpoint")
+		}
+		if status, ok := registry.Status(""); !ok || !status.Failing() {
+			t.Fatalf("Expected the broken target to be failing, got %+v", status)
+		}
+		if got := len(registry.Statuses()); got != 3 {
+			t.Fatalf("Expected 3 targets, got %d", got)
+		}
+	})
+
+	t.Run("Open circuits fail fast", func(t *testing.T) {
+		c3 := cluster.ServerInfo{ClusterID: "c3", Endpoint: "c3:9884"}
+		registry.GetOrCreateClusterPersisterForTarget(c3)
+		down := created["c3:9884"]
+		down.err = errors.New("unavailable")
+		for i := 0; i < 5; i++ {
+			err := registry.Send(context.Background(), c3, update)
+			if batch.IsPermanent(err) {
+				t.Fatalf("Expected a retryable error, got %v", err)
+			}
+			if i >= 3 && !errors.Is(err, breaker.ErrOpen) {
+				t.Fatalf("Expected send %d to fail fast, got %v", i, err)
+			}
+		}
+		if down.sent != 3 {
+			t.Fatalf("Expected 3 sends to reach the cluster, got %d", down.sent)
+		}
+		if status, _ := registry.Status("c3:9884"); status.Circuit != breaker.Open {
+			t.Fatalf("Expected an open circuit, got %s", status.Circuit)
+		}
+
+		// Permanent failures mean the cluster is answering
+		if err := registry.Send(context.Background(), c1, update); err != nil {
+			t.Fatal(err)
+		}
+		created["c1:9884"].err = batch.Permanent(errors.New("bad case"))
+		for i := 0; i < 5; i++ {
+			registry.Send(context.Background(), c1, update)
+		}
+		if status, _ := registry.Status("c1:9884"); status.Circuit != breaker.Closed {
+			t.Fatalf("Expected permanent failures to keep the circuit closed, got %s", status.Circuit)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		if err := registry.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !created["c1:9884"].closed || len(registry.Statuses()) != 0 {
+			t.Fatal("Expected every persister to be closed and forgotten")
+		}
+	})
+}
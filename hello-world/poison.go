@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"hello-world/batch"
+	"hello-world/breaker"
+	"hello-world/cluster"
+	"hello-world/compression"
+	"hello-world/deadletter"
+	"hello-world/digimodel"
+	"hello-world/dispatch"
+	"hello-world/lag"
+	"hello-world/rules"
+	"hello-world/source"
+)
+
+// maxTrackedAttempts bounds the memory used to count attempts across warm invocations
+const maxTrackedAttempts = 10000
+
+var (
+	// errDecodeFailure is wrapped into errors for records that are not a valid StreamEventRequest
+	errDecodeFailure = errors.New("invalid stream event")
+
+	// deadLetterSink receives poison records. When nil, poison records are logged and dropped.
+	deadLetterSink deadletter.Sink
+
+	// maxRecordAttempts is the retry budget of a record before it is dead-lettered. Zero means unlimited.
+	maxRecordAttempts = 0
+
+	// recordAttempts counts failed attempts per sequence number.
+	// Counts only survive within a warm execution environment, so the retry budget is best effort.
+	recordAttempts = newAttemptCounter()
+)
+
+type attemptCounter struct {
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+func newAttemptCounter() *attemptCounter {
+	return &attemptCounter{attempts: map[string]int{}}
+}
+
+// Increment records a failed attempt for key and returns the number of attempts so far
+func (c *attemptCounter) Increment(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.attempts[key]; !ok && len(c.attempts) >= maxTrackedAttempts {
+		c.attempts = map[string]int{}
+	}
+	c.attempts[key]++
+	return c.attempts[key]
+}
+
+// Forget drops the attempt count for key once the record no longer needs to be retried
+func (c *attemptCounter) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.attempts, key)
+}
+
+// routeFailedRecord decides what happens to a record that failed processing.
+// It returns the reason and true when the record was dead-lettered and must not be reported as a batch item failure.
+func routeFailedRecord(ctx context.Context, record source.Record, err error) (deadletter.Reason, bool) {
+	log := loggerFrom(ctx)
+
+	// A record cut short by the invocation deadline did not get a fair attempt, so it is retried without counting it
+	if ctx.Err() != nil {
+		log.Warn("Record did not finish before the invocation deadline", zap.Error(err))
+		return "", false
+	}
+
+	// A send refused by an open circuit never reached the cluster, so it is retried without counting it either
+	if errors.Is(err, breaker.ErrOpen) {
+		log.Warn("Cluster circuit is open, the record will be retried", zap.Error(err))
+		return "", false
+	}
+
+	attempts := recordAttempts.Increment(record.UniqueID())
+
+	var reason deadletter.Reason
+	switch {
+	case classifyError(err) == batch.KindPermanent:
+		reason = deadLetterReason(err)
+	case maxRecordAttempts > 0 && attempts >= maxRecordAttempts && errors.Is(err, cluster.ErrNoCluster):
+		reason = deadletter.ReasonNoCluster
+	case maxRecordAttempts > 0 && attempts >= maxRecordAttempts:
+		reason = deadletter.ReasonRetryBudgetExceeded
+	default:
+		log.Error("Failed to process record", zap.Int("attempts", attempts), zap.Error(err))
+		return "", false
+	}
+
+	dlErr := handlePoisonRecord(ctx, record, reason, attempts, err)
+	if dlErr != nil {
+		// The record must not be lost, so it is retried until the sink accepts it
+		log.Error("Failed to dead-letter record", zap.Error(dlErr))
+		return "", false
+	}
+	recordAttempts.Forget(record.UniqueID())
+	return reason, true
+}
+
+// handlePoisonRecord takes ownership of a record that can never be processed successfully
+func handlePoisonRecord(ctx context.Context, record source.Record, reason deadletter.Reason, attempts int, err error) error {
+	loggerFrom(ctx).Warn("Dead-lettering record", zap.String("reason", string(reason)), zap.Int("attempts", attempts), zap.Error(err))
+
+	if deadLetterSink == nil {
+		return nil
+	}
+
+	return deadLetterSink.Send(ctx, deadletter.Entry{
+		EventSource:    record.EventSource,
+		Data:           record.Data,
+		ShardID:        record.Partition,
+		SequenceNumber: record.UniqueID(),
+		PartitionKey:   record.Key,
+		Reason:         reason,
+		Error:          err.Error(),
+		Attempts:       attempts,
+		FailedAt:       time.Now().UTC(),
+	})
+}
+
+// deadLetterReason maps a permanent failure onto the reason it is dead-lettered with
+func deadLetterReason(err error) deadletter.Reason {
+	switch {
+	case errors.Is(err, errDecodeFailure):
+		return deadletter.ReasonDecodeFailure
+	case errors.Is(err, digimodel.ErrValidation):
+		return deadletter.ReasonValidationFailure
+	case errors.Is(err, cluster.ErrNoCluster):
+		return deadletter.ReasonNoCluster
+	case errors.Is(err, dispatch.ErrUnregistered):
+		return deadletter.ReasonUnhandledEvent
+	case errors.Is(err, rules.ErrDenied):
+		return deadletter.ReasonDenied
+	case errors.Is(err, lag.ErrStale):
+		return deadletter.ReasonStale
+	case errors.Is(err, compression.ErrTooLarge):
+		return deadletter.ReasonPayloadTooLarge
+	default:
+		return deadletter.ReasonPermanentFailure
+	}
+}
+
+// newDeadLetterSink builds the sink configured by the environment.
+// DeadLetterQueueUrl selects SQS, optionally sent to DeadLetterEndpoint for a local stand-in,
+// and DeadLetterFile selects a local file. With neither set poison records are only logged.
+func newDeadLetterSink(ctx context.Context) (deadletter.Sink, error) {
+	if queueURL := os.Getenv("DeadLetterQueueUrl"); queueURL != "" {
+		client, err := deadletter.NewSQSClient(ctx, os.Getenv("DeadLetterEndpoint"))
+		if err != nil {
+			return nil, err
+		}
+		return deadletter.NewSQSSink(client, queueURL), nil
+	}
+	if path := os.Getenv("DeadLetterFile"); path != "" {
+		return deadletter.NewFileSink(path)
+	}
+	return nil, nil
+}
+
+// loadRetryBudget reads MaxRecordAttempts from the environment
+func loadRetryBudget() (int, error) {
+	value := os.Getenv("MaxRecordAttempts")
+	if value == "" {
+		return 0, nil
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts < 0 {
+		return 0, fmt.Errorf("invalid MaxRecordAttempts %q", value)
+	}
+	return attempts, nil
+}
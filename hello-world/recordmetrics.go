@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"hello-world/breaker"
+	"hello-world/metrics"
+	"hello-world/rules"
+)
+
+var (
+	// metricSink receives the Embedded Metric Format documents of every invocation
+	metricSink metrics.Sink = metrics.NewWriterSink(os.Stdout)
+
+	// metricNamespace and metricDimensionSets are loaded from MetricNamespace and MetricDimensions on cold start
+	metricNamespace     = metrics.DefaultNamespace
+	metricDimensionSets = metrics.DefaultDimensionSets()
+)
+
+// unknownDimension is the dimension value of records that could not be decoded
+const unknownDimension = "Unknown"
+
+// recordSummary describes what happened to a record, it is filled in while the record is processed
+type recordSummary struct {
+	started     bool
+	eventObject string
+	eventType   string
+	// skipped is true when the record succeeded without being dispatched to a handler
+	skipped bool
+	// producerLag and streamLag are zero when they could not be measured
+	producerLag time.Duration
+	streamLag   time.Duration
+	// compressedSize and decompressedSize are zero when the record was not compressed.
+	// The events of a compressed envelope each carry the sizes of the whole envelope.
+	compressedSize   int
+	decompressedSize int
+}
+
+// dimensions returns the metric dimensions of the record, with reason when it failed
+func (s *recordSummary) dimensions(reason string) metrics.Dimensions {
+	dims := metrics.Dimensions{
+		metrics.DimensionEventObject: s.eventObject,
+		metrics.DimensionEventType:   s.eventType,
+	}
+	if s.eventObject == "" {
+		dims[metrics.DimensionEventObject] = unknownDimension
+		dims[metrics.DimensionEventType] = unknownDimension
+	}
+	if reason != "" {
+		dims[metrics.DimensionReason] = reason
+	}
+	return dims
+}
+
+// outcome names what happened to a record that succeeded
+func (s *recordSummary) outcome() string {
+	if s.skipped {
+		return "skipped"
+	}
+	return "succeeded"
+}
+
+// record counts the outcome of the record.
+// A reason without err means the record was dead-lettered, a reason with err means it is reported as failed.
+func (s *recordSummary) record(recorder *metrics.Recorder, reason string, err error) {
+	switch {
+	case err != nil:
+		recorder.Count(metrics.RecordsFailed, s.dimensions(reason), 1)
+	case reason != "":
+		recorder.Count(metrics.RecordsDeadLettered, s.dimensions(reason), 1)
+	case s.skipped:
+		recorder.Count(metrics.RecordsSkipped, s.dimensions(""), 1)
+	default:
+		recorder.Count(metrics.RecordsSucceeded, s.dimensions(""), 1)
+	}
+}
+
+// failureReason names why a record is reported as a batch item failure
+func failureReason(ctx context.Context, err error) string {
+	switch {
+	case ctx.Err() != nil:
+		return "Deadline"
+	case errors.Is(err, breaker.ErrOpen):
+		return "CircuitOpen"
+	case errors.Is(err, rules.ErrDenied):
+		return "Denied"
+	default:
+		return "Error"
+	}
+}
+
+// emitMetrics counts every record received along with the records that were never started, then flushes recorder.
+// Metrics must not fail the batch, so a sink error is only logged.
+func emitMetrics(ctx context.Context, recorder *metrics.Recorder, summaries []recordSummary) {
+	reportLag(ctx, recorder, summaries)
+	reportCompression(recorder, summaries)
+	for i := range summaries {
+		summary := &summaries[i]
+		recorder.Count(metrics.RecordsReceived, summary.dimensions(""), 1)
+		if !summary.started {
+			recorder.Count(metrics.RecordsFailed, summary.dimensions("Unprocessed"), 1)
+		}
+	}
+	if err := recorder.Flush(metricSink, time.Now()); err != nil {
+		loggerFrom(ctx).Warn("Failed to emit metrics", zap.Error(err))
+	}
+}
+
+// loadMetricsConfig reads MetricNamespace and MetricDimensions from the environment, see metrics.ParseDimensionSets
+func loadMetricsConfig() (string, [][]string, error) {
+	namespace := os.Getenv("MetricNamespace")
+	if namespace == "" {
+		namespace = metrics.DefaultNamespace
+	}
+	sets, err := metrics.ParseDimensionSets(os.Getenv("MetricDimensions"))
+	if err != nil {
+		return "", nil, err
+	}
+	return namespace, sets, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.uber.org/zap"
+	"hello-world/batch"
+	"hello-world/digimodel"
+	"hello-world/rules"
+)
+
+// recordRules decides which events are processed, it is loaded from the environment on cold start
+var recordRules = rules.Default()
+
+// applyRules checks event against recordRules and logs the decision trace.
+// It returns true when the event must not be dispatched, along with the error to report for its record.
+func applyRules(ctx context.Context, event *digimodel.StreamEventRequest) (bool, error) {
+	decision := recordRules.Evaluate(event)
+	loggerFrom(ctx).Info("Rules decided the action for the event",
+		zap.Stringer("action", decision.Action), zap.String("rule", decision.Rule), zap.Strings("trace", decision.Trace))
+
+	switch decision.Action {
+	case rules.ActionAllow:
+		return false, nil
+	case rules.ActionDeadLetter:
+		return true, batch.Permanent(decision.Err())
+	default:
+		return true, decision.Err()
+	}
+}
+
+// loadRules reads the rules configured by the environment.
+// RecordRules holds the rules as JSON and RecordRulesFile names a JSON file of rules.
+// With neither set the default rules are used.
+func loadRules() (*rules.Rules, error) {
+	if value := os.Getenv("RecordRules"); value != "" {
+		return rules.Parse([]byte(value))
+	}
+	if path := os.Getenv("RecordRulesFile"); path != "" {
+		return rules.Load(path)
+	}
+	return rules.Default(), nil
+}
@@ -0,0 +1,222 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"hello-world/digimodel"
+)
+
+// ErrDenied is wrapped by the error returned for records that a rule fails or dead-letters
+var ErrDenied = errors.New("stream event denied by rule")
+
+// Action is what happens to a record that a rule does not allow
+type Action int
+
+// Acceptable `Action` values
+const (
+	// ActionAllow lets the record through, it is the outcome when every rule allows the record
+	ActionAllow Action = iota
+	// ActionSkip treats the record as successfully processed without dispatching it
+	ActionSkip
+	// ActionFail reports the record as a retryable failure
+	ActionFail
+	// ActionDeadLetter reports the record as a permanent failure so it is dead-lettered
+	ActionDeadLetter
+)
+
+var action_name = map[Action]string{
+	ActionAllow:      "allow",
+	ActionSkip:       "skip",
+	ActionFail:       "fail",
+	ActionDeadLetter: "deadletter",
+}
+
+var action_value = map[string]Action{
+	"skip":       ActionSkip,
+	"fail":       ActionFail,
+	"deadletter": ActionDeadLetter,
+}
+
+func (a Action) String() string {
+	return action_name[a]
+}
+
+func (a Action) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON reads an action name. An empty action means skip, "allow" is not a valid rule action.
+func (a *Action) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		*a = ActionSkip
+		return nil
+	}
+	action, ok := action_value[s]
+	if !ok {
+		return fmt.Errorf("invalid rule action %q", s)
+	}
+	*a = action
+	return nil
+}
+
+// Values is a list of values a rule matches, compared without regard to case or surrounding space.
+// Numbers are accepted so business units can be listed as they appear in events.
+type Values []string
+
+func (v *Values) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	values := make(Values, 0, len(raw))
+	for _, r := range raw {
+		var s string
+		if err := json.Unmarshal(r, &s); err != nil {
+			var n json.Number
+			if err := json.Unmarshal(r, &n); err != nil {
+				return fmt.Errorf("invalid rule value %s", r)
+			}
+			s = n.String()
+		}
+		values = append(values, s)
+	}
+	*v = values
+	return nil
+}
+
+func (v Values) contains(value string) bool {
+	value = normalize(value)
+	for _, candidate := range v {
+		if normalize(candidate) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(value string) string {
+	return strings.TrimSpace(strings.ToLower(value))
+}
+
+// Rule allows or denies a single attribute of an event.
+// A value on the Deny list, or a value missing from a non-empty Allow list, gets the Action, which defaults to skip.
+type Rule struct {
+	Allow  Values `json:"allow,omitempty"`
+	Deny   Values `json:"deny,omitempty"`
+	Action Action `json:"action"`
+}
+
+// check returns the Action for value and a description of why
+func (r *Rule) check(value string) (Action, string) {
+	action := r.Action
+	if action == ActionAllow {
+		action = ActionSkip
+	}
+	switch {
+	case r.Deny.contains(value):
+		return action, "denied"
+	case len(r.Allow) > 0 && !r.Allow.contains(value):
+		return action, "not allowed"
+	default:
+		return ActionAllow, "allowed"
+	}
+}
+
+// Rules decides which stream events are processed.
+// Rules are checked in the order tenant, business unit, event type and case status, and the first one
+// that does not allow the event decides its Action.
+type Rules struct {
+	Tenants       *Rule `json:"tenants,omitempty"`
+	BusinessUnits *Rule `json:"businessUnits,omitempty"`
+	EventTypes    *Rule `json:"eventTypes,omitempty"`
+	// CaseStatuses only applies to events that carry a case status
+	CaseStatuses *Rule `json:"caseStatuses,omitempty"`
+}
+
+// Default returns the rules applied when none are configured, which fail every event of tenant "0"
+func Default() *Rules {
+	return &Rules{
+		Tenants: &Rule{Deny: Values{"0"}, Action: ActionFail},
+	}
+}
+
+// Parse reads Rules from JSON such as
+//
+//	{"tenants": {"deny": ["0"], "action": "fail"}, "caseStatuses": {"deny": ["closed"], "action": "skip"}}
+func Parse(data []byte) (*Rules, error) {
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+	return &rules, nil
+}
+
+// Load reads Rules from the JSON file at path
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Decision is the outcome of evaluating Rules against an event
+type Decision struct {
+	Action Action
+	// Rule names the rule that decided the Action, it is empty when the event was allowed
+	Rule string
+	// Trace lists every rule that was checked and its result, such as "tenant 11: allowed"
+	Trace []string
+}
+
+// Err returns the error for a record that fails or is dead-lettered, and nil otherwise
+func (d Decision) Err() error {
+	if d.Action != ActionFail && d.Action != ActionDeadLetter {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrDenied, d.Trace[len(d.Trace)-1])
+}
+
+// Evaluate checks event against every rule
+func (r *Rules) Evaluate(event *digimodel.StreamEventRequest) Decision {
+	status := event.Data.Case.Status
+	if event.Data.Case.ID == "" {
+		status = event.Data.Contact.Status
+	}
+
+	checks := []struct {
+		name  string
+		rule  *Rule
+		value string
+	}{
+		{"tenant", r.Tenants, event.Data.Brand.TenantID},
+		{"businessUnit", r.BusinessUnits, strconv.Itoa(int(event.Data.Brand.BusinessUnitID))},
+		{"eventType", r.EventTypes, event.EventType.String()},
+		{"caseStatus", r.CaseStatuses, status},
+	}
+
+	var decision Decision
+	for _, c := range checks {
+		if c.rule == nil || (c.name == "caseStatus" && strings.TrimSpace(c.value) == "") {
+			continue
+		}
+		action, why := c.rule.check(c.value)
+		decision.Trace = append(decision.Trace, fmt.Sprintf("%s %q: %s", c.name, c.value, why))
+		if action != ActionAllow {
+			decision.Action = action
+			decision.Rule = c.name
+			return decision
+		}
+	}
+	return decision
+}
@@ -0,0 +1,84 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"hello-world/digimodel"
+)
+
+func event(tenantID string, businessUnitID int32, eventType digimodel.EventType, status string) *digimodel.StreamEventRequest {
+	var e digimodel.StreamEventRequest
+	e.EventType = eventType
+	e.Data.Brand.TenantID = tenantID
+	e.Data.Brand.BusinessUnitID = businessUnitID
+	e.Data.Case.ID = "case-1"
+	e.Data.Case.Status = status
+	return &e
+}
+
+func TestEvaluate(t *testing.T) {
+	rules, err := Parse([]byte(`{
+		"tenants": {"deny": ["0"], "action": "fail"},
+		"businessUnits": {"allow": [4, "5"], "action": "deadletter"},
+		"eventTypes": {"allow": ["CaseStatusChanged"]},
+		"caseStatuses": {"deny": ["Closed"], "action": "skip"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		event  *digimodel.StreamEventRequest
+		action Action
+		rule   string
+	}{
+		{"Allowed", event("11", 4, digimodel.EventType_CaseStatusChanged, "open"), ActionAllow, ""},
+		{"Denied tenant", event(" 0 ", 4, digimodel.EventType_CaseStatusChanged, "open"), ActionFail, "tenant"},
+		{"Business unit not allowed", event("11", 6, digimodel.EventType_CaseStatusChanged, "open"), ActionDeadLetter, "businessUnit"},
+		{"Event type defaults to skip", event("11", 5, digimodel.EventType_Undefined, "open"), ActionSkip, "eventType"},
+		{"Denied case status", event("11", 5, digimodel.EventType_CaseStatusChanged, "closed"), ActionSkip, "caseStatus"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := rules.Evaluate(test.event)
+			if decision.Action != test.action || decision.Rule != test.rule {
+				t.Fatalf("Expected %s by %q, got %s by %q with trace %v", test.action, test.rule, decision.Action, decision.Rule, decision.Trace)
+			}
+		})
+	}
+
+	t.Run("Trace lists every rule checked", func(t *testing.T) {
+		decision := rules.Evaluate(event("11", 4, digimodel.EventType_CaseStatusChanged, "closed"))
+		if len(decision.Trace) != 4 || decision.Trace[3] != `caseStatus "closed": denied` {
+			t.Fatalf("Expected a trace of 4 rules, got %v", decision.Trace)
+		}
+	})
+
+	t.Run("Err", func(t *testing.T) {
+		if err := rules.Evaluate(event("0", 4, digimodel.EventType_CaseStatusChanged, "open")).Err(); !errors.Is(err, ErrDenied) {
+			t.Fatalf("Expected ErrDenied, got %v", err)
+		}
+		if err := rules.Evaluate(event("11", 4, digimodel.EventType_CaseStatusChanged, "closed")).Err(); err != nil {
+			t.Fatalf("Expected no error for a skipped event, got %v", err)
+		}
+	})
+}
+
+func TestDefault(t *testing.T) {
+	if decision := Default().Evaluate(event("0", 1, digimodel.EventType_CaseStatusChanged, "open")); decision.Action != ActionFail {
+		t.Fatalf("Expected tenant 0 to fail, got %s", decision.Action)
+	}
+	if decision := Default().Evaluate(event("11", 1, digimodel.EventType_CaseStatusChanged, "closed")); decision.Action != ActionAllow {
+		t.Fatalf("Expected tenant 11 to be allowed, got %s", decision.Action)
+	}
+}
+
+func TestParseInvalidAction(t *testing.T) {
+	var rule Rule
+	if err := json.Unmarshal([]byte(`{"deny": ["0"], "action": "allow"}`), &rule); err == nil {
+		t.Fatal("Expected an error for an allow action")
+	}
+}
@@ -0,0 +1,110 @@
+package source
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DefaultDynamoDBAttribute is the NewImage attribute expected to hold the event when none is configured
+const DefaultDynamoDBAttribute = "event"
+
+// FromDynamoDB converts the records of a DynamoDB Streams event in batch order.
+// The data of a record is the attribute of its NewImage, either a serialized event in a string or binary attribute
+// or a map attribute whose names match the JSON of the event. A NewImage without the attribute is the event itself.
+// Deletions have no NewImage to process and are left out.
+func FromDynamoDB(event events.DynamoDBEvent, attribute string) []Record {
+	records := make([]Record, 0, len(event.Records))
+	for _, record := range event.Records {
+		if record.EventName == string(events.DynamoDBOperationTypeRemove) {
+			continue
+		}
+		records = append(records, Record{
+			EventSource: EventSourceDynamoDB,
+			ID:          record.Change.SequenceNumber,
+			Partition:   record.EventSourceArn,
+			Key:         itemKey(record.Change.Keys),
+			Data:        imageData(record.Change.NewImage, attribute),
+			ArrivedAt:   record.Change.ApproximateCreationDateTime.Time,
+		})
+	}
+	return records
+}
+
+// imageData extracts the event from a NewImage, nil when there is none so that the record fails to decode
+func imageData(image map[string]events.DynamoDBAttributeValue, attribute string) []byte {
+	if len(image) == 0 {
+		return nil
+	}
+	value, ok := image[attribute]
+	if !ok {
+		value = events.NewMapAttribute(image)
+	}
+	switch value.DataType() {
+	case events.DataTypeString:
+		return []byte(value.String())
+	case events.DataTypeBinary:
+		return value.Binary()
+	}
+	data, err := json.Marshal(plain(value))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// plain converts an attribute value into the value encoding/json would have decoded from the same JSON
+func plain(value events.DynamoDBAttributeValue) any {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return value.String()
+	case events.DataTypeNumber:
+		return json.Number(value.Number())
+	case events.DataTypeBinary:
+		return value.Binary()
+	case events.DataTypeBoolean:
+		return value.Boolean()
+	case events.DataTypeStringSet:
+		return value.StringSet()
+	case events.DataTypeBinarySet:
+		return value.BinarySet()
+	case events.DataTypeNumberSet:
+		numbers := make([]json.Number, len(value.NumberSet()))
+		for i, number := range value.NumberSet() {
+			numbers[i] = json.Number(number)
+		}
+		return numbers
+	case events.DataTypeList:
+		list := make([]any, len(value.List()))
+		for i, item := range value.List() {
+			list[i] = plain(item)
+		}
+		return list
+	case events.DataTypeMap:
+		m := make(map[string]any, len(value.Map()))
+		for name, item := range value.Map() {
+			m[name] = plain(item)
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+// itemKey renders the primary key of an item, which is what DynamoDB Streams keeps records in order by
+func itemKey(keys map[string]events.DynamoDBAttributeValue) string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		value, _ := json.Marshal(plain(keys[name]))
+		parts[i] = name + "=" + string(value)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,65 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// SplitEnvelope splits a multi-event envelope into its events.
+// An envelope is either a JSON array of events or newline-delimited JSON with an event per line.
+// It returns false when data is a single JSON value, including one spread over several lines,
+// or cannot be split, including invalid JSON whose first line is not an event,
+// in which case data should be processed as a single event.
+func SplitEnvelope(data []byte) ([][]byte, bool) {
+	trimmed := bytes.TrimSpace(data)
+	if json.Valid(trimmed) {
+		if !bytes.HasPrefix(trimmed, []byte("[")) {
+			return nil, false
+		}
+		var events []json.RawMessage
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, false
+		}
+		split := make([][]byte, len(events))
+		for i, event := range events {
+			split[i] = event
+		}
+		return split, true
+	}
+
+	// A payload whose first line is not a JSON object is a corrupt or truncated event rather than an envelope,
+	// so it is kept whole. A later invalid line still becomes an event of its own,
+	// so it fails without failing the rest of the envelope.
+	var split [][]byte
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			split = append(split, line)
+		}
+	}
+	if len(split) < 2 || !isJSONObject(split[0]) {
+		return nil, false
+	}
+	return split, true
+}
+
+// isJSONObject reports whether data is a single valid JSON object
+func isJSONObject(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("{")) && json.Valid(data)
+}
+
+// Split replaces record by the events of its envelope, each keeping the ID and Key of record.
+// It returns false when record does not carry an envelope.
+func Split(record Record) ([]Record, bool) {
+	events, ok := SplitEnvelope(record.Data)
+	if !ok {
+		return nil, false
+	}
+	records := make([]Record, len(events))
+	for i, event := range events {
+		records[i] = record
+		records[i].Data = event
+		records[i].Envelope = true
+		records[i].EventIndex = i
+	}
+	return records, true
+}
@@ -0,0 +1,48 @@
+package source
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// KafkaPosition is where a record was read from a Kafka topic
+type KafkaPosition struct {
+	Topic     string
+	Partition int64
+	Offset    int64
+}
+
+// FromKafka converts the records of a Kafka event, from Amazon MSK or a self-managed cluster.
+// Records are ordered by topic partition and then by offset, and keyed by their topic partition,
+// which is the only ordering Kafka guarantees. A value that is not valid base64 is kept as is so it fails to decode.
+func FromKafka(event events.KafkaEvent) []Record {
+	partitions := make([]string, 0, len(event.Records))
+	for partition := range event.Records {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+
+	var records []Record
+	for _, partition := range partitions {
+		for _, record := range event.Records[partition] {
+			data, err := base64.StdEncoding.DecodeString(record.Value)
+			if err != nil {
+				data = []byte(record.Value)
+			}
+			key := fmt.Sprintf("%s-%d", record.Topic, record.Partition)
+			records = append(records, Record{
+				EventSource: EventSourceKafka,
+				ID:          fmt.Sprintf("%s:%d", key, record.Offset),
+				Partition:   key,
+				Key:         key,
+				Data:        data,
+				ArrivedAt:   record.Timestamp.Time,
+				Kafka:       &KafkaPosition{Topic: record.Topic, Partition: record.Partition, Offset: record.Offset},
+			})
+		}
+	}
+	return records
+}
@@ -0,0 +1,44 @@
+package source
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FromKinesis converts the records of a Kinesis event in batch order.
+// A KPL aggregated record is replaced by its user records, each keyed by its own partition key.
+func FromKinesis(event events.KinesisEvent) []Record {
+	records := make([]Record, 0, len(event.Records))
+	for _, record := range event.Records {
+		kinesisRecord := Record{
+			EventSource: EventSourceKinesis,
+			ID:          record.Kinesis.SequenceNumber,
+			Partition:   ShardID(record.EventID),
+			Key:         record.Kinesis.PartitionKey,
+			Data:        record.Kinesis.Data,
+			ArrivedAt:   record.Kinesis.ApproximateArrivalTimestamp.Time,
+		}
+
+		userRecords, ok := Deaggregate(record.Kinesis.Data)
+		if !ok {
+			records = append(records, kinesisRecord)
+			continue
+		}
+		for i, userRecord := range userRecords {
+			subRecord := kinesisRecord
+			subRecord.Key = userRecord.PartitionKey
+			subRecord.Data = userRecord.Data
+			subRecord.Aggregated = true
+			subRecord.SubSequenceNumber = i
+			records = append(records, subRecord)
+		}
+	}
+	return records
+}
+
+// ShardID extracts the shard ID from a Kinesis event ID, which has the form "shardId-000000000000:<sequence number>"
+func ShardID(eventID string) string {
+	id, _, _ := strings.Cut(eventID, ":")
+	return id
+}
@@ -0,0 +1,156 @@
+package source
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// kplMagic prefixes every record aggregated by the Kinesis Producer Library
+var kplMagic = []byte{0xF3, 0x89, 0x9A, 0xC2}
+
+var errInvalidAggregate = errors.New("invalid KPL aggregated record")
+
+// UserRecord is one of the records packed into a KPL aggregated record
+type UserRecord struct {
+	PartitionKey    string
+	ExplicitHashKey string
+	Data            []byte
+}
+
+// Deaggregate unpacks a KPL aggregated record, which is the magic prefix, an AggregatedRecord protobuf message
+// and the MD5 digest of that message. It returns false when data is not an aggregated record, including when
+// the digest does not match, in which case data should be processed as a single record.
+func Deaggregate(data []byte) ([]UserRecord, bool) {
+	if len(data) < len(kplMagic)+md5.Size || !bytes.HasPrefix(data, kplMagic) {
+		return nil, false
+	}
+	body := data[len(kplMagic) : len(data)-md5.Size]
+	digest := md5.Sum(body)
+	if !bytes.Equal(digest[:], data[len(data)-md5.Size:]) {
+		return nil, false
+	}
+	records, err := parseAggregatedRecord(body)
+	if err != nil {
+		return nil, false
+	}
+	return records, true
+}
+
+// parseAggregatedRecord decodes the AggregatedRecord message of the KPL:
+//
+//	message AggregatedRecord {
+//	  repeated string partition_key_table     = 1;
+//	  repeated string explicit_hash_key_table = 2;
+//	  repeated Record records                 = 3;
+//	}
+func parseAggregatedRecord(b []byte) ([]UserRecord, error) {
+	var partitionKeys, explicitHashKeys []string
+	var records []aggregatedRecord
+	err := parseMessage(b, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			partitionKeys = append(partitionKeys, string(value))
+		case 2:
+			explicitHashKeys = append(explicitHashKeys, string(value))
+		case 3:
+			record, err := parseRecord(value)
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userRecords := make([]UserRecord, len(records))
+	for i, record := range records {
+		if record.partitionKeyIndex >= uint64(len(partitionKeys)) {
+			return nil, errInvalidAggregate
+		}
+		userRecords[i] = UserRecord{PartitionKey: partitionKeys[record.partitionKeyIndex], Data: record.data}
+		if record.hasExplicitHashKey {
+			if record.explicitHashKeyIndex >= uint64(len(explicitHashKeys)) {
+				return nil, errInvalidAggregate
+			}
+			userRecords[i].ExplicitHashKey = explicitHashKeys[record.explicitHashKeyIndex]
+		}
+	}
+	return userRecords, nil
+}
+
+type aggregatedRecord struct {
+	partitionKeyIndex    uint64
+	explicitHashKeyIndex uint64
+	hasExplicitHashKey   bool
+	data                 []byte
+}
+
+// parseRecord decodes the Record message of the KPL, tags are not used and are skipped:
+//
+//	message Record {
+//	  required uint64 partition_key_index     = 1;
+//	  optional uint64 explicit_hash_key_index = 2;
+//	  required bytes  data                    = 3;
+//	  repeated Tag    tags                    = 4;
+//	}
+func parseRecord(b []byte) (aggregatedRecord, error) {
+	var record aggregatedRecord
+	err := parseMessage(b, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1, 2:
+			index, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if num == 1 {
+				record.partitionKeyIndex = index
+			} else {
+				record.explicitHashKeyIndex, record.hasExplicitHashKey = index, true
+			}
+		case 3:
+			record.data = value
+		}
+		return nil
+	})
+	return record, err
+}
+
+// parseMessage calls field with the number and raw value of every field of a protobuf message.
+// Varints are passed still encoded and length delimited values without their length.
+func parseMessage(b []byte, field func(num protowire.Number, value []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(b)
+			if n >= 0 {
+				value = b[:n]
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := field(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package source
+
+import (
+	"fmt"
+	"time"
+
+	"hello-world/compression"
+)
+
+// Acceptable `Record.EventSource` values, as named by Lambda in the events it delivers
+const (
+	EventSourceKinesis  = "aws:kinesis"
+	EventSourceSQS      = "aws:sqs"
+	EventSourceDynamoDB = "aws:dynamodb"
+	// EventSourceKafka covers both Amazon MSK and self-managed Kafka clusters
+	EventSourceKafka = "aws:kafka"
+)
+
+// Record is a single record of a batch, whichever event source delivered it
+type Record struct {
+	// EventSource names the service that delivered the record, such as EventSourceKinesis
+	EventSource string
+	// ID is the item identifier reported when the record fails, a Kinesis or DynamoDB sequence number, SQS message ID
+	// or Kafka topic partition and offset
+	ID string
+	// Partition is where the record was read from, a Kinesis shard ID, SQS queue ARN, DynamoDB stream ARN or Kafka topic partition
+	Partition string
+	// Key groups records that the source keeps in order, a Kinesis partition key, SQS message group ID, DynamoDB item key
+	// or Kafka topic partition.
+	// It is empty when the source does not order records, such as a standard SQS queue.
+	Key string
+	// Data is the payload of the record, decompressed once it has been unpacked
+	Data []byte
+	// Compression is the format the payload was compressed with.
+	// CompressedSize and DecompressedSize are set when Data was decompressed from a payload of CompressedSize bytes,
+	// DecompressedSize being the size of the whole payload, which may have been an envelope of several events.
+	Compression      compression.Format
+	CompressedSize   int
+	DecompressedSize int
+	// DecompressErr is set when the payload could not be decompressed, Data then holds the payload as it was received
+	DecompressErr error
+	// ArrivedAt is when the source accepted the record, zero when unknown
+	ArrivedAt time.Time
+	// Kafka is set for records read from Kafka
+	Kafka *KafkaPosition
+	// Aggregated is set for the user records of a KPL aggregated Kinesis record, which all share its ID.
+	// SubSequenceNumber is the position of the user record within the aggregated record.
+	Aggregated        bool
+	SubSequenceNumber int
+	// Envelope is set for the events of a multi-event envelope, which all share the ID of the record that carried them.
+	// EventIndex is the position of the event within the envelope.
+	Envelope   bool
+	EventIndex int
+}
+
+// Shared reports whether the record shares its ID with other records of the batch, which are retried together
+func (r Record) Shared() bool {
+	return r.Aggregated || r.Envelope
+}
+
+// UniqueID identifies the record within its source, telling apart the user records of a KPL aggregated record
+// as "<ID>:<sub-sequence number>" and the events of an envelope as "<ID>/<event index>"
+func (r Record) UniqueID() string {
+	id := r.ID
+	if r.Aggregated {
+		id = fmt.Sprintf("%s:%d", id, r.SubSequenceNumber)
+	}
+	if r.Envelope {
+		id = fmt.Sprintf("%s/%d", id, r.EventIndex)
+	}
+	return id
+}
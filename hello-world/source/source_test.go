@@ -0,0 +1,252 @@
+package source
+
+import (
+	"crypto/md5"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestFromKinesis(t *testing.T) {
+	arrived := time.Unix(1714557600, 0)
+	records := FromKinesis(events.KinesisEvent{Records: []events.KinesisEventRecord{{
+		EventID: "shardId-000000000003:42",
+		Kinesis: events.KinesisRecord{
+			Data:                        []byte("data"),
+			PartitionKey:                "partition",
+			SequenceNumber:              "42",
+			ApproximateArrivalTimestamp: events.SecondsEpochTime{Time: arrived},
+		},
+	}}})
+
+	want := []Record{{EventSource: EventSourceKinesis, ID: "42", Partition: "shardId-000000000003", Key: "partition", Data: []byte("data"), ArrivedAt: arrived}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("Expected %+v, got %+v", want, records)
+	}
+}
+
+func TestFromSQS(t *testing.T) {
+	t.Run("FIFO queue", func(t *testing.T) {
+		records := FromSQS(events.SQSEvent{Records: []events.SQSMessage{{
+			MessageId:      "m1",
+			Body:           "body",
+			EventSourceARN: "arn:aws:sqs:us-west-2:000000000000:queue.fifo",
+			Attributes:     map[string]string{"MessageGroupId": "group", "SentTimestamp": "1714557600123"},
+		}}})
+
+		want := []Record{{EventSource: EventSourceSQS, ID: "m1", Partition: "arn:aws:sqs:us-west-2:000000000000:queue.fifo", Key: "group",
+			Data: []byte("body"), ArrivedAt: time.UnixMilli(1714557600123)}}
+		if !reflect.DeepEqual(records, want) {
+			t.Fatalf("Expected %+v, got %+v", want, records)
+		}
+	})
+
+	t.Run("Standard queue", func(t *testing.T) {
+		records := FromSQS(events.SQSEvent{Records: []events.SQSMessage{{MessageId: "m1"}}})
+		if records[0].Key != "" || !records[0].ArrivedAt.IsZero() {
+			t.Fatalf("Expected no key or arrival time, got %+v", records[0])
+		}
+	})
+}
+
+func TestFromDynamoDB(t *testing.T) {
+	created := time.Unix(1714557600, 0)
+	change := func(sequenceNumber string, eventName string, image map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+		return events.DynamoDBEventRecord{
+			EventName:      eventName,
+			EventSourceArn: "arn:aws:dynamodb:us-west-2:000000000000:table/cases/stream/1",
+			Change: events.DynamoDBStreamRecord{
+				ApproximateCreationDateTime: events.SecondsEpochTime{Time: created},
+				Keys: map[string]events.DynamoDBAttributeValue{
+					"tenantId": events.NewStringAttribute("11"),
+					"caseId":   events.NewStringAttribute("case-1"),
+				},
+				NewImage:       image,
+				SequenceNumber: sequenceNumber,
+			},
+		}
+	}
+	records := FromDynamoDB(events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		change("1", "INSERT", map[string]events.DynamoDBAttributeValue{
+			"event": events.NewStringAttribute(`{"eventId":"e1"}`),
+		}),
+		change("2", "REMOVE", nil),
+		change("3", "MODIFY", map[string]events.DynamoDBAttributeValue{
+			"eventId": events.NewStringAttribute("e3"),
+			"data": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+				"brand": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+					"businessUnitId": events.NewNumberAttribute("1"),
+				}),
+				"tags":    events.NewStringSetAttribute([]string{"vip"}),
+				"deleted": events.NewNullAttribute(),
+			}),
+		}),
+		change("4", "INSERT", nil),
+	}}, DefaultDynamoDBAttribute)
+
+	if len(records) != 3 {
+		t.Fatalf("Expected deletions to be left out, got %d records", len(records))
+	}
+	for i, want := range []string{
+		`{"eventId":"e1"}`,
+		`{"data":{"brand":{"businessUnitId":1},"deleted":null,"tags":["vip"]},"eventId":"e3"}`,
+		``,
+	} {
+		if got := string(records[i].Data); got != want {
+			t.Fatalf("Expected data %s, got %s", want, got)
+		}
+	}
+	record := records[0]
+	if record.EventSource != EventSourceDynamoDB || record.ID != "1" || record.Key != `caseId="case-1",tenantId="11"` ||
+		record.Partition != "arn:aws:dynamodb:us-west-2:000000000000:table/cases/stream/1" || !record.ArrivedAt.Equal(created) {
+		t.Fatalf("Unexpected record %+v", record)
+	}
+}
+
+func TestFromKafka(t *testing.T) {
+	produced := time.UnixMilli(1714557600123)
+	kafkaRecord := func(partition int64, offset int64, value string) events.KafkaRecord {
+		return events.KafkaRecord{
+			Topic:     "cases",
+			Partition: partition,
+			Offset:    offset,
+			Timestamp: events.MilliSecondsEpochTime{Time: produced},
+			Value:     value,
+		}
+	}
+	records := FromKafka(events.KafkaEvent{Records: map[string][]events.KafkaRecord{
+		"cases-1": {kafkaRecord(1, 7, "YQ==")},
+		"cases-0": {kafkaRecord(0, 3, "Yg=="), kafkaRecord(0, 4, "not base64!")},
+	}})
+
+	var got []string
+	for _, record := range records {
+		got = append(got, fmt.Sprintf("%s %s %s", record.ID, record.Key, record.Data))
+	}
+	if got := strings.Join(got, "|"); got != "cases-0:3 cases-0 b|cases-0:4 cases-0 not base64!|cases-1:7 cases-1 a" {
+		t.Fatalf("Unexpected records %s", got)
+	}
+	want := KafkaPosition{Topic: "cases", Partition: 1, Offset: 7}
+	if last := records[2]; *last.Kafka != want || last.EventSource != EventSourceKafka || !last.ArrivedAt.Equal(produced) {
+		t.Fatalf("Unexpected record %+v", last)
+	}
+}
+
+// aggregate packs data into a KPL aggregated record, user record i getting partition key keys[i]
+func aggregate(keys []string, data ...string) []byte {
+	var body []byte
+	for _, key := range keys {
+		body = protowire.AppendTag(body, 1, protowire.BytesType)
+		body = protowire.AppendString(body, key)
+	}
+	for i, d := range data {
+		var record []byte
+		record = protowire.AppendTag(record, 1, protowire.VarintType)
+		record = protowire.AppendVarint(record, uint64(i))
+		record = protowire.AppendTag(record, 3, protowire.BytesType)
+		record = protowire.AppendString(record, d)
+		body = protowire.AppendTag(body, 3, protowire.BytesType)
+		body = protowire.AppendBytes(body, record)
+	}
+	digest := md5.Sum(body)
+	return append(append(append([]byte{}, kplMagic...), body...), digest[:]...)
+}
+
+func TestDeaggregate(t *testing.T) {
+	t.Run("Aggregated record", func(t *testing.T) {
+		records, ok := Deaggregate(aggregate([]string{"a", "b"}, "one", "two"))
+		want := []UserRecord{{PartitionKey: "a", Data: []byte("one")}, {PartitionKey: "b", Data: []byte("two")}}
+		if !ok || !reflect.DeepEqual(records, want) {
+			t.Fatalf("Expected %+v, got %+v (%v)", want, records, ok)
+		}
+	})
+
+	t.Run("Plain record", func(t *testing.T) {
+		if _, ok := Deaggregate([]byte(`{"eventId":"e1"}`)); ok {
+			t.Fatal("Expected a plain record not to be aggregated")
+		}
+	})
+
+	t.Run("Digest mismatch", func(t *testing.T) {
+		data := aggregate([]string{"a"}, "one")
+		data[len(data)-1] ^= 0xFF
+		if _, ok := Deaggregate(data); ok {
+			t.Fatal("Expected a record with a bad digest not to be aggregated")
+		}
+	})
+
+	t.Run("Partition key out of range", func(t *testing.T) {
+		if _, ok := Deaggregate(aggregate(nil, "one")); ok {
+			t.Fatal("Expected a record with a missing partition key not to be aggregated")
+		}
+	})
+}
+
+func TestFromKinesisAggregated(t *testing.T) {
+	records := FromKinesis(events.KinesisEvent{Records: []events.KinesisEventRecord{{
+		EventID: "shardId-000000000001:42",
+		Kinesis: events.KinesisRecord{Data: aggregate([]string{"a", "b"}, "one", "two"), PartitionKey: "a", SequenceNumber: "42"},
+	}}})
+
+	var got []string
+	for _, record := range records {
+		got = append(got, fmt.Sprintf("%s %s %s %s", record.ID, record.UniqueID(), record.Key, record.Data))
+	}
+	if got := strings.Join(got, "|"); got != "42 42:0 a one|42 42:1 b two" {
+		t.Fatalf("Unexpected records %s", got)
+	}
+}
+
+func TestSplitEnvelope(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data string
+		want []string
+		ok   bool
+	}{
+		{"Single event", `{"eventId":"e1"}`, nil, false},
+		{"Single event over several lines", "{\n  \"eventId\": \"e1\"\n}\n", nil, false},
+		{"JSON array", ` [{"eventId":"e1"}, {"eventId":"e2"}] `, []string{`{"eventId":"e1"}`, `{"eventId":"e2"}`}, true},
+		{"Empty JSON array", `[]`, []string{}, true},
+		{"Newline-delimited JSON", "{\"eventId\":\"e1\"}\n\n{\"eventId\":\"e2\"}\r\n", []string{`{"eventId":"e1"}`, `{"eventId":"e2"}`}, true},
+		{"Invalid line", "{\"eventId\":\"e1\"}\nnot json", []string{`{"eventId":"e1"}`, `not json`}, true},
+		{"Invalid event", `not json`, nil, false},
+		{"Invalid event over several lines", "not json\n{\"eventId\":\"e1\"}", nil, false},
+		{"Truncated event over several lines", "{\n  \"eventId\": \"e1\",\n  \"eventType\": ", nil, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			split, ok := SplitEnvelope([]byte(test.data))
+			if ok != test.ok {
+				t.Fatalf("Expected ok %v, got %v", test.ok, ok)
+			}
+			got := []string{}
+			for _, event := range split {
+				got = append(got, string(event))
+			}
+			if test.ok && !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUniqueID(t *testing.T) {
+	for _, test := range []struct {
+		record Record
+		want   string
+	}{
+		{Record{ID: "42"}, "42"},
+		{Record{ID: "42", Aggregated: true, SubSequenceNumber: 1}, "42:1"},
+		{Record{ID: "42", Envelope: true, EventIndex: 2}, "42/2"},
+		{Record{ID: "42", Aggregated: true, SubSequenceNumber: 1, Envelope: true, EventIndex: 2}, "42:1/2"},
+	} {
+		if got := test.record.UniqueID(); got != test.want {
+			t.Fatalf("Expected %s, got %s", test.want, got)
+		}
+	}
+}
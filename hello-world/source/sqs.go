@@ -0,0 +1,34 @@
+package source
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FromSQS converts the messages of an SQS event in batch order.
+// Messages from a FIFO queue are keyed by their message group, messages from a standard queue have no key.
+func FromSQS(event events.SQSEvent) []Record {
+	records := make([]Record, len(event.Records))
+	for i, message := range event.Records {
+		records[i] = Record{
+			EventSource: EventSourceSQS,
+			ID:          message.MessageId,
+			Partition:   message.EventSourceARN,
+			Key:         message.Attributes["MessageGroupId"],
+			Data:        []byte(message.Body),
+			ArrivedAt:   epochMillis(message.Attributes["SentTimestamp"]),
+		}
+	}
+	return records
+}
+
+// epochMillis parses an SQS timestamp attribute, which is milliseconds since the epoch, and returns zero when it is not set
+func epochMillis(value string) time.Time {
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
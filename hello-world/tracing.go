@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"hello-world/source"
+	"hello-world/tracing"
+)
+
+const tracerName = "hello-world"
+
+// traceFlushTimeout bounds how long an invocation waits for its spans to be exported
+const traceFlushTimeout = 2 * time.Second
+
+// tracerProvider is set up from TracingExporter on cold start, spans are not recorded until then
+var tracerProvider *tracing.Provider
+
+// setupTracing installs the exporter named by TracingExporter, which is "none", "stdout" or "memory".
+// No exporter sends spans to X-Ray, so a deployed function drops its spans despite Tracing: Active
+// unless TracingExporter is stdout, which writes them to the function logs.
+func setupTracing() (*tracing.Provider, error) {
+	exporter, err := tracing.NewExporter(os.Getenv("TracingExporter"), os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	return tracing.Setup(exporter, false), nil
+}
+
+// startInvocationSpan starts the span covering a whole batch
+func startInvocationSpan(ctx context.Context, records int) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{attribute.Int("messaging.batch.message_count", records)}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		attributes = append(attributes, attribute.String("faas.invocation_id", lc.AwsRequestID))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "handler", trace.WithAttributes(attributes...))
+}
+
+// startRecordSpan starts the span covering a single record, event attributes are added once it is decoded
+func startRecordSpan(ctx context.Context, record source.Record) (context.Context, trace.Span) {
+	var attributes []attribute.KeyValue
+	switch record.EventSource {
+	case source.EventSourceSQS:
+		attributes = []attribute.KeyValue{
+			attribute.String("aws.sqs.queue_arn", record.Partition),
+			attribute.String("aws.sqs.message_id", record.ID),
+			attribute.String("aws.sqs.message_group_id", record.Key),
+		}
+	case source.EventSourceDynamoDB:
+		attributes = []attribute.KeyValue{
+			attribute.String("aws.dynamodb.stream_arn", record.Partition),
+			attribute.String("aws.dynamodb.sequence_number", record.ID),
+			attribute.String("aws.dynamodb.item_key", record.Key),
+		}
+	case source.EventSourceKafka:
+		attributes = []attribute.KeyValue{
+			attribute.String("messaging.destination.name", record.Kafka.Topic),
+			attribute.Int64("messaging.kafka.destination.partition", record.Kafka.Partition),
+			attribute.Int64("messaging.kafka.message.offset", record.Kafka.Offset),
+		}
+	default:
+		attributes = []attribute.KeyValue{
+			attribute.String("aws.kinesis.shard_id", record.Partition),
+			attribute.String("aws.kinesis.sequence_number", record.ID),
+			attribute.String("aws.kinesis.partition_key", record.Key),
+		}
+		if record.Aggregated {
+			attributes = append(attributes, attribute.Int("aws.kinesis.sub_sequence_number", record.SubSequenceNumber))
+		}
+	}
+	if record.Envelope {
+		attributes = append(attributes, attribute.Int("record.event_index", record.EventIndex))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "processRecord", trace.WithAttributes(attributes...))
+}
+
+// endRecordSpan records the outcome of a record on its span and ends it.
+// A dead-lettered record keeps its error so the span shows why it was dead-lettered.
+func endRecordSpan(span trace.Span, outcome string, reason string, err error) {
+	span.SetAttributes(attribute.String("record.outcome", outcome))
+	if reason != "" {
+		span.SetAttributes(attribute.String("record.failure_reason", reason))
+	}
+	tracing.End(span, err)
+}
+
+// flushTraces exports the spans of the invocation, a failure is only logged.
+// It gives up after traceFlushTimeout so a slow exporter cannot hold the invocation until its deadline.
+func flushTraces(ctx context.Context) {
+	flushCtx, cancel := context.WithTimeout(ctx, traceFlushTimeout)
+	defer cancel()
+	if err := tracerProvider.ForceFlush(flushCtx); err != nil {
+		loggerFrom(ctx).Warn("Failed to flush traces", zap.Error(err))
+	}
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"hello-world/digimodel"
+)
+
+// Attribute keys shared by every span that handles a stream event
+const (
+	AttributeEventID     = attribute.Key("event.id")
+	AttributeEventObject = attribute.Key("event.object")
+	AttributeEventType   = attribute.Key("event.type")
+	AttributeTenantID    = attribute.Key("tenant.id")
+)
+
+// EventAttributes returns the span attributes identifying event
+func EventAttributes(event *digimodel.StreamEventRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		AttributeEventID.String(event.EventID),
+		AttributeEventObject.String(event.EventObject.String()),
+		AttributeEventType.String(event.EventType.String()),
+		AttributeTenantID.String(strings.TrimSpace(event.Data.Brand.TenantID)),
+	}
+}
+
+// End records err on span, when there is one, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Exporter names accepted by NewExporter
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterMemory = "memory"
+)
+
+// NewExporter returns the exporter named by name, writing to w for the stdout exporter.
+// The none exporter, which is also selected by an empty name, returns nil so spans are not recorded at all.
+func NewExporter(name string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch strings.TrimSpace(strings.ToLower(name)) {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterMemory:
+		return tracetest.NewInMemoryExporter(), nil
+	default:
+		return nil, fmt.Errorf("invalid TracingExporter %q", name)
+	}
+}
+
+// Provider wraps the global tracer provider so it can be flushed before Lambda freezes the environment
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Setup installs a tracer provider sending spans to exporter as the global provider,
+// along with the W3C trace context propagator. A nil exporter only installs the propagator.
+// Spans are sent to the exporter as they end when sync is true, which tests use to read spans right away.
+func Setup(exporter sdktrace.SpanExporter, sync bool) *Provider {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if exporter == nil {
+		return &Provider{}
+	}
+
+	processor := sdktrace.NewBatchSpanProcessor(exporter)
+	if sync {
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+	otel.SetTracerProvider(provider)
+	return &Provider{provider: provider}
+}
+
+// ForceFlush exports every ended span that is still buffered
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	if p == nil || p.provider == nil {
+		return nil
+	}
+	return p.provider.ForceFlush(ctx)
+}
+
+// Shutdown flushes and stops the provider
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil || p.provider == nil {
+		return nil
+	}
+	return p.provider.Shutdown(ctx)
+}
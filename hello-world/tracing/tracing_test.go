@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"hello-world/digimodel"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	provider := Setup(spans, true)
+	defer provider.Shutdown(context.Background())
+
+	conn, err := grpc.NewClient("passthrough:///vc", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	ctx = metadata.AppendToOutgoingContext(ctx, "tenant", "11")
+	var sent metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		sent, _ = metadata.FromOutgoingContext(ctx)
+		return status.Error(codes.Unavailable, "down")
+	}
+
+	err = UnaryClientInterceptor()(ctx, "/vc.casestatus.v1.CaseStatusService/SendCaseStatusChangedEvent", nil, nil, conn, invoker)
+	parent.End()
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected the invoker error, got %v", err)
+	}
+
+	traceparent := sent.Get("traceparent")
+	if len(traceparent) != 1 || !bytes.Contains([]byte(traceparent[0]), []byte(parent.SpanContext().TraceID().String())) {
+		t.Fatalf("Expected the trace context in the metadata, got %v", sent)
+	}
+	if len(sent.Get("tenant")) != 1 {
+		t.Fatalf("Expected existing metadata to be kept, got %v", sent)
+	}
+
+	ended := spans.GetSpans()
+	if len(ended) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(ended))
+	}
+	client := ended[0]
+	if client.SpanKind != trace.SpanKindClient || client.Parent.SpanID() != parent.SpanContext().SpanID() || client.Status.Description != err.Error() {
+		t.Fatalf("Unexpected client span %+v", client)
+	}
+}
+
+func TestEventAttributes(t *testing.T) {
+	var event digimodel.StreamEventRequest
+	event.EventID = "e1"
+	event.EventType = digimodel.EventType_CaseStatusChanged
+	event.Data.Brand.TenantID = " 11 "
+
+	attributes := map[string]string{}
+	for _, kv := range EventAttributes(&event) {
+		attributes[string(kv.Key)] = kv.Value.AsString()
+	}
+	if attributes["event.id"] != "e1" || attributes["event.type"] != "CaseStatusChanged" || attributes["tenant.id"] != "11" {
+		t.Fatalf("Unexpected attributes %v", attributes)
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	for _, name := range []string{"", "none"} {
+		if exporter, err := NewExporter(name, nil); exporter != nil || err != nil {
+			t.Fatalf("Expected no exporter for %q, got %v %v", name, exporter, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	exporter, err := NewExporter("stdout", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := Setup(exporter, true)
+	_, span := otel.Tracer("test").Start(context.Background(), "stdout-span")
+	End(span, errors.New("failed"))
+	provider.Shutdown(context.Background())
+	if !bytes.Contains(buf.Bytes(), []byte("stdout-span")) {
+		t.Fatalf("Expected the span to be written, got %s", buf.String())
+	}
+
+	if exporter, err := NewExporter("memory", nil); err != nil {
+		t.Fatal(err)
+	} else if _, ok := exporter.(*tracetest.InMemoryExporter); !ok {
+		t.Fatalf("Expected an in-memory exporter, got %T", exporter)
+	}
+
+	if _, err := NewExporter("xray", nil); err == nil {
+		t.Fatal("Expected an error for an unknown exporter")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"hello-world/breaker"
+	"hello-world/cluster"
+	"hello-world/digimodel"
+	"hello-world/dispatch"
+	"hello-world/persister"
+	"hello-world/vcclient"
+)
+
+var (
+	// clusterResolver locates the VC cluster of a tenant, it is built from the environment on cold start
+	clusterResolver = cluster.NewResolver(cluster.NewStaticSource(nil, nil), cluster.DefaultTTL, cluster.DefaultNegativeTTL)
+
+	// vcDialOptions are added to every VC connection
+	vcDialOptions []grpc.DialOption
+
+	// clusterPersisters holds one persister per cluster endpoint so warm invocations reuse connections.
+	// Its status shows which clusters are currently failing.
+	// It is rebuilt on cold start with the circuit breaker thresholds from the environment.
+	clusterPersisters = persister.NewRegistry(newVCPersister, breaker.DefaultConfig())
+)
+
+// newVCPersister connects to the VC at target over gRPC
+func newVCPersister(target cluster.ServerInfo) (persister.Persister, error) {
+	return vcclient.Dial(target.Endpoint, vcclient.DefaultCallTimeout, vcDialOptions...)
+}
+
+// logFailingClusters reports every cluster whose most recent send failed
+func logFailingClusters(log *zap.Logger) {
+	for _, status := range clusterPersisters.Failing() {
+		log.Warn("Cluster is failing",
+			zap.String("clusterId", status.ClusterID),
+			zap.String("endpoint", status.Endpoint),
+			zap.Int("consecutiveFailures", status.ConsecutiveFailures),
+			zap.Stringer("circuit", status.Circuit),
+			zap.Time("lastErrorAt", status.LastErrorAt),
+			zap.String("lastError", status.LastError))
+	}
+}
+
+// loadBreakerConfig reads the circuit breaker thresholds CircuitFailureThreshold, CircuitOpenTimeout
+// and CircuitHalfOpenMaxCalls from the environment
+func loadBreakerConfig() (breaker.Config, error) {
+	config := breaker.DefaultConfig()
+	var err error
+	if config.FailureThreshold, err = intFromEnv("CircuitFailureThreshold", config.FailureThreshold); err != nil {
+		return config, err
+	}
+	if config.OpenTimeout, err = durationFromEnv("CircuitOpenTimeout", config.OpenTimeout); err != nil {
+		return config, err
+	}
+	if config.HalfOpenMaxCalls, err = intFromEnv("CircuitHalfOpenMaxCalls", config.HalfOpenMaxCalls); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// newClusterResolver builds the resolver configured by the environment.
+// ClusterLookupUrl selects the cluster lookup service, TenantClusterMap a DynamoDB table of assignments
+// and ClusterMapFile a static JSON file of assignments.
+// VcGrpcTarget, when set, is used for tenants the table or file does not assign.
+// ClusterCacheTTL and ClusterNegativeCacheTTL override how long lookups are cached.
+func newClusterResolver(ctx context.Context) (*cluster.Resolver, error) {
+	ttl, err := durationFromEnv("ClusterCacheTTL", cluster.DefaultTTL)
+	if err != nil {
+		return nil, err
+	}
+	negativeTTL, err := durationFromEnv("ClusterNegativeCacheTTL", cluster.DefaultNegativeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback *cluster.ServerInfo
+	if target := os.Getenv("VcGrpcTarget"); target != "" {
+		fallback = &cluster.ServerInfo{ClusterID: "default", Endpoint: target}
+	}
+
+	var source cluster.Source
+	switch {
+	case os.Getenv("ClusterLookupUrl") != "":
+		source = cluster.NewHTTPSource(os.Getenv("ClusterLookupUrl"), 2*time.Second)
+	case os.Getenv("TenantClusterMap") != "":
+		client, err := cluster.NewDynamoDBClient(ctx, os.Getenv("TenantClusterMapEndpoint"))
+		if err != nil {
+			return nil, err
+		}
+		source = cluster.NewDynamoDBSource(client, os.Getenv("TenantClusterMap"), fallback)
+	case os.Getenv("ClusterMapFile") != "":
+		source, err = cluster.LoadStaticSource(os.Getenv("ClusterMapFile"), fallback)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		source = cluster.NewStaticSource(nil, fallback)
+	}
+	return cluster.NewResolver(source, ttl, negativeTTL), nil
+}
+
+// checkClusterSource fails when registry handles CaseStatusChanged events but no cluster source is configured,
+// as every such event would otherwise fail to find a cluster
+func checkClusterSource(registry *dispatch.Registry) error {
+	if _, ok := registry.Lookup(dispatch.AnyEventObject, digimodel.EventType_CaseStatusChanged); !ok {
+		return nil
+	}
+	for _, name := range []string{"VcGrpcTarget", "TenantClusterMap", "ClusterMapFile", "ClusterLookupUrl"} {
+		if os.Getenv(name) != "" {
+			return nil
+		}
+	}
+	return errors.New("CaseStatusChanged events need one of VcGrpcTarget, TenantClusterMap, ClusterMapFile or ClusterLookupUrl")
+}
+
+// durationFromEnv reads a duration such as "90s" from the environment variable name
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return d, nil
+}
+
+// intFromEnv reads a positive integer from the environment variable name
+func intFromEnv(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
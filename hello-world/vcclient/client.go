@@ -0,0 +1,95 @@
+package vcclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"hello-world/batch"
+	"hello-world/digimodel"
+	"hello-world/tracing"
+	"hello-world/vcpb"
+)
+
+// DefaultCallTimeout bounds a single call when the caller's context allows more time
+const DefaultCallTimeout = 2 * time.Second
+
+// Client sends case status changes to a VC cluster over gRPC
+type Client struct {
+	conn        *grpc.ClientConn
+	service     vcpb.CaseStatusServiceClient
+	callTimeout time.Duration
+}
+
+// Dial returns a Client for the VC at target, such as "vc-cluster-1.example.com:9884".
+// The connection is established lazily on the first call and plaintext is used unless opts say otherwise.
+// Every call is traced and carries the trace context in its metadata.
+func Dial(target string, callTimeout time.Duration, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor()),
+	}, opts...)
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grpc client for %s: %w", target, err)
+	}
+	return &Client{
+		conn:        conn,
+		service:     vcpb.NewCaseStatusServiceClient(conn),
+		callTimeout: callTimeout,
+	}, nil
+}
+
+// Close closes the underlying connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// SendCaseStatusChanged sends update to the VC.
+// The call deadline is the earlier of the call timeout and the deadline of ctx, which carries the Lambda deadline.
+// Failures are classified with batch.Permanent or batch.Retryable, see Classify.
+func (c *Client) SendCaseStatusChanged(ctx context.Context, update *digimodel.DigiCaseStatusUpdate) error {
+	if c.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.callTimeout)
+		defer cancel()
+	}
+
+	_, err := c.service.SendCaseStatusChangedEvent(ctx, &vcpb.CaseStatusChangedEvent{
+		EventId:         update.EventID,
+		TenantId:        update.TenantID,
+		BusinessUnitId:  update.BusinessUnitID,
+		CaseId:          update.CaseID,
+		ContactGuid:     update.ContactGUID,
+		InteractionId:   update.InteractionID,
+		Status:          update.Status,
+		RoutingQueueId:  update.RoutingQueueID,
+		StatusUpdatedAt: update.StatusUpdatedAt,
+	})
+	return Classify(err)
+}
+
+// Classify maps a gRPC status onto a retryable or permanent record failure.
+// AlreadyExists means the VC applied this event on an earlier delivery, so it counts as success.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("failed to send case status changed event: %w", err)
+	switch status.Code(err) {
+	case codes.AlreadyExists:
+		return nil
+	case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.FailedPrecondition,
+		codes.OutOfRange, codes.Unimplemented, codes.DataLoss:
+		return batch.Permanent(err)
+	default:
+		// Unavailable, DeadlineExceeded, ResourceExhausted, Aborted, Internal, Unknown, Canceled and Unauthenticated
+		// are expected to clear up on their own
+		return batch.Retryable(err)
+	}
+}
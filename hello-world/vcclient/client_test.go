@@ -0,0 +1,92 @@
+package vcclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"hello-world/batch"
+	"hello-world/digimodel"
+	"hello-world/vcclient/vcfake"
+	"hello-world/vcpb"
+)
+
+var testUpdate = &digimodel.DigiCaseStatusUpdate{
+	EventID:         "e1",
+	TenantID:        "11",
+	BusinessUnitID:  4,
+	CaseID:          "c1",
+	ContactGUID:     "g1",
+	Status:          "closed",
+	StatusUpdatedAt: timestamppb.Now(),
+}
+
+func TestClassify(t *testing.T) {
+	tests := map[codes.Code]string{
+		codes.OK:                 "success",
+		codes.AlreadyExists:      "success",
+		codes.Unavailable:        "retryable",
+		codes.DeadlineExceeded:   "retryable",
+		codes.ResourceExhausted:  "retryable",
+		codes.Unauthenticated:    "retryable",
+		codes.InvalidArgument:    "permanent",
+		codes.NotFound:           "permanent",
+		codes.FailedPrecondition: "permanent",
+	}
+	for code, expected := range tests {
+		err := Classify(status.Error(code, "test"))
+		got := "retryable"
+		switch {
+		case err == nil:
+			got = "success"
+		case batch.IsPermanent(err):
+			got = "permanent"
+		}
+		if got != expected {
+			t.Errorf("Classify(%s) = %s, expected %s", code, got, expected)
+		}
+	}
+}
+
+func TestSendCaseStatusChanged(t *testing.T) {
+	server := vcfake.Start()
+	defer server.Stop()
+	client, err := Dial(vcfake.Target, time.Second, server.Dialer()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	t.Run("Accepted", func(t *testing.T) {
+		if err := client.SendCaseStatusChanged(context.Background(), testUpdate); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		events := server.Events()
+		if len(events) != 1 || events[0].CaseId != "c1" || events[0].BusinessUnitId != 4 || !events[0].StatusUpdatedAt.AsTime().Equal(testUpdate.StatusUpdatedAt.AsTime()) {
+			t.Fatalf("Unexpected events %v", events)
+		}
+	})
+
+	t.Run("Deadline from context", func(t *testing.T) {
+		server.HandleWith(func(ctx context.Context, _ *vcpb.CaseStatusChangedEvent) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		defer server.HandleWith(nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := client.SendCaseStatusChanged(ctx, testUpdate)
+		if status.Code(errors.Unwrap(err)) != codes.DeadlineExceeded || batch.IsPermanent(err) {
+			t.Fatalf("Expected a retryable DeadlineExceeded, got %v", err)
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Fatal("The call should have been cut short by the context deadline")
+		}
+	})
+}
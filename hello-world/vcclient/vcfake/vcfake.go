@@ -0,0 +1,121 @@
+package vcfake
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"hello-world/vcpb"
+)
+
+// Target is the client target to use with Dialer, it skips name resolution
+const Target = "passthrough:///vcfake"
+
+// Server is an in-process CaseStatusService that records every request.
+// It listens on an in-memory connection, so tests need no network access.
+type Server struct {
+	vcpb.UnimplementedCaseStatusServiceServer
+
+	mu       sync.Mutex
+	events   []*vcpb.CaseStatusChangedEvent
+	metadata []metadata.MD
+	errors   []error
+	handler  func(ctx context.Context, event *vcpb.CaseStatusChangedEvent) error
+
+	listener *bufconn.Listener
+	server   *grpc.Server
+}
+
+// Start starts a Server on an in-memory listener
+func Start() *Server {
+	s := &Server{
+		listener: bufconn.Listen(1024 * 1024),
+		server:   grpc.NewServer(),
+	}
+	vcpb.RegisterCaseStatusServiceServer(s.server, s)
+	go s.server.Serve(s.listener)
+	return s
+}
+
+// Stop stops the server and closes the listener
+func (s *Server) Stop() {
+	s.server.Stop()
+}
+
+// Dialer returns the dial options that connect a client created for Target to this Server
+func (s *Server) Dialer() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+}
+
+// FailNext makes the next calls return errs in order, a nil entry lets that call succeed
+func (s *Server) FailNext(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, errs...)
+}
+
+// HandleWith replaces the default behavior of accepting every event.
+// It is useful to block until a deadline or to fail events selectively.
+func (s *Server) HandleWith(handler func(ctx context.Context, event *vcpb.CaseStatusChangedEvent) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = handler
+}
+
+// Events returns every event the server accepted
+func (s *Server) Events() []*vcpb.CaseStatusChangedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*vcpb.CaseStatusChangedEvent(nil), s.events...)
+}
+
+// Metadata returns the incoming metadata of every call, accepted or not
+func (s *Server) Metadata() []metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]metadata.MD(nil), s.metadata...)
+}
+
+// Reset forgets every recorded event and queued error
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+	s.metadata = nil
+	s.errors = nil
+	s.handler = nil
+}
+
+// SendCaseStatusChangedEvent implements vcpb.CaseStatusServiceServer
+func (s *Server) SendCaseStatusChangedEvent(ctx context.Context, event *vcpb.CaseStatusChangedEvent) (*vcpb.CaseStatusChangedResponse, error) {
+	s.mu.Lock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.metadata = append(s.metadata, md)
+	var err error
+	if len(s.errors) > 0 {
+		err, s.errors = s.errors[0], s.errors[1:]
+	}
+	handler := s.handler
+	s.mu.Unlock()
+
+	if err == nil && handler != nil {
+		err = handler(ctx, event)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	s.mu.Unlock()
+	return &vcpb.CaseStatusChangedResponse{}, nil
+}
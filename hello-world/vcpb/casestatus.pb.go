@@ -0,0 +1,240 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v4.25.3
+// source: casestatus.proto
+
+package vcpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CaseStatusChangedEvent mirrors digimodel.DigiCaseStatusUpdate
+type CaseStatusChangedEvent struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	EventId         string                 `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	TenantId        string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	BusinessUnitId  int32                  `protobuf:"varint,3,opt,name=business_unit_id,json=businessUnitId,proto3" json:"business_unit_id,omitempty"`
+	CaseId          string                 `protobuf:"bytes,4,opt,name=case_id,json=caseId,proto3" json:"case_id,omitempty"`
+	ContactGuid     string                 `protobuf:"bytes,5,opt,name=contact_guid,json=contactGuid,proto3" json:"contact_guid,omitempty"`
+	InteractionId   string                 `protobuf:"bytes,6,opt,name=interaction_id,json=interactionId,proto3" json:"interaction_id,omitempty"`
+	Status          string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	RoutingQueueId  string                 `protobuf:"bytes,8,opt,name=routing_queue_id,json=routingQueueId,proto3" json:"routing_queue_id,omitempty"`
+	StatusUpdatedAt *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=status_updated_at,json=statusUpdatedAt,proto3" json:"status_updated_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CaseStatusChangedEvent) Reset() {
+	*x = CaseStatusChangedEvent{}
+	mi := &file_casestatus_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CaseStatusChangedEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CaseStatusChangedEvent) ProtoMessage() {}
+
+func (x *CaseStatusChangedEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_casestatus_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CaseStatusChangedEvent.ProtoReflect.Descriptor instead.
+func (*CaseStatusChangedEvent) Descriptor() ([]byte, []int) {
+	return file_casestatus_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CaseStatusChangedEvent) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *CaseStatusChangedEvent) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *CaseStatusChangedEvent) GetBusinessUnitId() int32 {
+	if x != nil {
+		return x.BusinessUnitId
+	}
+	return 0
+}
+
+func (x *CaseStatusChangedEvent) GetCaseId() string {
+	if x != nil {
+		return x.CaseId
+	}
+	return ""
+}
+
+func (x *CaseStatusChangedEvent) GetContactGuid() string {
+	if x != nil {
+		return x.ContactGuid
+	}
+	return ""
+}
+
+func (x *CaseStatusChangedEvent) GetInteractionId() string {
+	if x != nil {
+		return x.InteractionId
+	}
+	return ""
+}
+
+func (x *CaseStatusChangedEvent) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *CaseStatusChangedEvent) GetRoutingQueueId() string {
+	if x != nil {
+		return x.RoutingQueueId
+	}
+	return ""
+}
+
+func (x *CaseStatusChangedEvent) GetStatusUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StatusUpdatedAt
+	}
+	return nil
+}
+
+type CaseStatusChangedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CaseStatusChangedResponse) Reset() {
+	*x = CaseStatusChangedResponse{}
+	mi := &file_casestatus_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CaseStatusChangedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CaseStatusChangedResponse) ProtoMessage() {}
+
+func (x *CaseStatusChangedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_casestatus_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CaseStatusChangedResponse.ProtoReflect.Descriptor instead.
+func (*CaseStatusChangedResponse) Descriptor() ([]byte, []int) {
+	return file_casestatus_proto_rawDescGZIP(), []int{1}
+}
+
+var File_casestatus_proto protoreflect.FileDescriptor
+
+const file_casestatus_proto_rawDesc = "" +
+	"\n" +
+	"\x10casestatus.proto\x12\x10vc.casestatus.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe7\x02\n" +
+	"\x16CaseStatusChangedEvent\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12(\n" +
+	"\x10business_unit_id\x18\x03 \x01(\x05R\x0ebusinessUnitId\x12\x17\n" +
+	"\acase_id\x18\x04 \x01(\tR\x06caseId\x12!\n" +
+	"\fcontact_guid\x18\x05 \x01(\tR\vcontactGuid\x12%\n" +
+	"\x0einteraction_id\x18\x06 \x01(\tR\rinteractionId\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x12(\n" +
+	"\x10routing_queue_id\x18\b \x01(\tR\x0eroutingQueueId\x12F\n" +
+	"\x11status_updated_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\x0fstatusUpdatedAt\"\x1b\n" +
+	"\x19CaseStatusChangedResponse2\x88\x01\n" +
+	"\x11CaseStatusService\x12s\n" +
+	"\x1aSendCaseStatusChangedEvent\x12(.vc.casestatus.v1.CaseStatusChangedEvent\x1a+.vc.casestatus.v1.CaseStatusChangedResponseB\x12Z\x10hello-world/vcpbb\x06proto3"
+
+var (
+	file_casestatus_proto_rawDescOnce sync.Once
+	file_casestatus_proto_rawDescData []byte
+)
+
+func file_casestatus_proto_rawDescGZIP() []byte {
+	file_casestatus_proto_rawDescOnce.Do(func() {
+		file_casestatus_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_casestatus_proto_rawDesc), len(file_casestatus_proto_rawDesc)))
+	})
+	return file_casestatus_proto_rawDescData
+}
+
+var file_casestatus_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_casestatus_proto_goTypes = []any{
+	(*CaseStatusChangedEvent)(nil),    // 0: vc.casestatus.v1.CaseStatusChangedEvent
+	(*CaseStatusChangedResponse)(nil), // 1: vc.casestatus.v1.CaseStatusChangedResponse
+	(*timestamppb.Timestamp)(nil),     // 2: google.protobuf.Timestamp
+}
+var file_casestatus_proto_depIdxs = []int32{
+	2, // 0: vc.casestatus.v1.CaseStatusChangedEvent.status_updated_at:type_name -> google.protobuf.Timestamp
+	0, // 1: vc.casestatus.v1.CaseStatusService.SendCaseStatusChangedEvent:input_type -> vc.casestatus.v1.CaseStatusChangedEvent
+	1, // 2: vc.casestatus.v1.CaseStatusService.SendCaseStatusChangedEvent:output_type -> vc.casestatus.v1.CaseStatusChangedResponse
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_casestatus_proto_init() }
+func file_casestatus_proto_init() {
+	if File_casestatus_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_casestatus_proto_rawDesc), len(file_casestatus_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_casestatus_proto_goTypes,
+		DependencyIndexes: file_casestatus_proto_depIdxs,
+		MessageInfos:      file_casestatus_proto_msgTypes,
+	}.Build()
+	File_casestatus_proto = out.File
+	file_casestatus_proto_goTypes = nil
+	file_casestatus_proto_depIdxs = nil
+}
@@ -0,0 +1,129 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v4.25.3
+// source: casestatus.proto
+
+package vcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CaseStatusService_SendCaseStatusChangedEvent_FullMethodName = "/vc.casestatus.v1.CaseStatusService/SendCaseStatusChangedEvent"
+)
+
+// CaseStatusServiceClient is the client API for CaseStatusService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CaseStatusService receives digital case status changes on a VC cluster
+type CaseStatusServiceClient interface {
+	// SendCaseStatusChangedEvent applies a single case status change.
+	// Implementations must treat a repeated event_id as already applied.
+	SendCaseStatusChangedEvent(ctx context.Context, in *CaseStatusChangedEvent, opts ...grpc.CallOption) (*CaseStatusChangedResponse, error)
+}
+
+type caseStatusServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCaseStatusServiceClient(cc grpc.ClientConnInterface) CaseStatusServiceClient {
+	return &caseStatusServiceClient{cc}
+}
+
+func (c *caseStatusServiceClient) SendCaseStatusChangedEvent(ctx context.Context, in *CaseStatusChangedEvent, opts ...grpc.CallOption) (*CaseStatusChangedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CaseStatusChangedResponse)
+	err := c.cc.Invoke(ctx, CaseStatusService_SendCaseStatusChangedEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CaseStatusServiceServer is the server API for CaseStatusService service.
+// All implementations must embed UnimplementedCaseStatusServiceServer
+// for forward compatibility.
+//
+// CaseStatusService receives digital case status changes on a VC cluster
+type CaseStatusServiceServer interface {
+	// SendCaseStatusChangedEvent applies a single case status change.
+	// Implementations must treat a repeated event_id as already applied.
+	SendCaseStatusChangedEvent(context.Context, *CaseStatusChangedEvent) (*CaseStatusChangedResponse, error)
+	mustEmbedUnimplementedCaseStatusServiceServer()
+}
+
+// UnimplementedCaseStatusServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCaseStatusServiceServer struct{}
+
+func (UnimplementedCaseStatusServiceServer) SendCaseStatusChangedEvent(context.Context, *CaseStatusChangedEvent) (*CaseStatusChangedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendCaseStatusChangedEvent not implemented")
+}
+func (UnimplementedCaseStatusServiceServer) mustEmbedUnimplementedCaseStatusServiceServer() {}
+func (UnimplementedCaseStatusServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeCaseStatusServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CaseStatusServiceServer will
+// result in compilation errors.
+type UnsafeCaseStatusServiceServer interface {
+	mustEmbedUnimplementedCaseStatusServiceServer()
+}
+
+func RegisterCaseStatusServiceServer(s grpc.ServiceRegistrar, srv CaseStatusServiceServer) {
+	// If the following call pancis, it indicates UnimplementedCaseStatusServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CaseStatusService_ServiceDesc, srv)
+}
+
+func _CaseStatusService_SendCaseStatusChangedEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CaseStatusChangedEvent)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaseStatusServiceServer).SendCaseStatusChangedEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaseStatusService_SendCaseStatusChangedEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaseStatusServiceServer).SendCaseStatusChangedEvent(ctx, req.(*CaseStatusChangedEvent))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CaseStatusService_ServiceDesc is the grpc.ServiceDesc for CaseStatusService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CaseStatusService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "vc.casestatus.v1.CaseStatusService",
+	HandlerType: (*CaseStatusServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendCaseStatusChangedEvent",
+			Handler:    _CaseStatusService_SendCaseStatusChangedEvent_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "casestatus.proto",
+}